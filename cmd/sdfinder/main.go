@@ -7,8 +7,11 @@ import (
 	"flag"
 	"log"
 	"net"
+	"net/netip"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/shlin168/sdfinder"
 	"github.com/shlin168/sdfinder/sources"
@@ -16,18 +19,26 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "quota" {
+		runQuota(os.Args[2:])
+		return
+	}
+
 	fset := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	srcPath := fset.String("src", "", "source file with domain list. domains should be seperated by '\n'")
 	domains := fset.String("d", "", "domains to get subdomains if not given by '-src'. sep by ','")
 	cfgPath := fset.String("cfg", "", "config file path for sources to define custom qps, retries, .... use default config if not given")
-	resolveIP := fset.Bool("ip", false, "whether resolve ip for given domain to query API that serve IP or not")
+	resolveIP := fset.Bool("ip", false, "whether resolve ip for given domain to query API that serve IP (and sweep the /24 netblock by API that serve CIDR) or not")
+	cidrsStr := fset.String("cidr", "", "cidrs to sweep by API that serve CIDR. sep by ','")
 	outPath := fset.String("out", "", "path to write the result in json line. Each line represents one related domain found by one source")
 	queriersStr := fset.String("q", "", "limit to given sources, sep by ','. Default using all sources")
 	worker := fset.Int("worker", sources.DefaultWorker, "concurrency for each API if config is not given")
+	deadline := fset.Duration("deadline", 0, "stop querying after given duration and keep the results found so far. no deadline if not given")
+	permute := fset.Bool("permute", false, "resolve permutations of found subdomains after all the sources finish, enabled if 'permutation' is given in config")
 	fset.Parse(os.Args[1:])
 
-	if len(*srcPath)+len(*domains) == 0 {
-		log.Fatal("domains should be either given by -src=<filepath> or -d=<domain>")
+	if len(*srcPath)+len(*domains)+len(*cidrsStr) == 0 {
+		log.Fatal("domains should be either given by -src=<filepath> or -d=<domain>, or cidrs should be given by -cidr=<cidr>")
 	}
 	if len(*srcPath) > 0 && len(*domains) > 0 {
 		log.Fatal("domains should be either given by -src=<filepath> or -d=<domain>, can not provide both")
@@ -35,6 +46,20 @@ func main() {
 	if *outPath == "" {
 		log.Fatal("out file path should be given by -out")
 	}
+	if *deadline < 0 {
+		log.Fatal("deadline should >= 0")
+	}
+	var cidrs []string
+	for _, cs := range strings.Split(*cidrsStr, ",") {
+		if cs = strings.TrimSpace(cs); len(cs) == 0 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cs)
+		if err != nil {
+			log.Fatalf("invalid cidr %q: %v", cs, err)
+		}
+		cidrs = append(cidrs, prefix.Masked().String())
+	}
 	if len(*cfgPath) > 0 {
 		if len(*queriersStr) > 0 {
 			log.Fatal("-q is skipped when -cfg=<configpath> is given")
@@ -48,6 +73,9 @@ func main() {
 		}
 	}
 
+	// api keys loaded from config are redacted in every log line
+	logrus.AddHook(sources.RedactHook{})
+
 	// read from config, or using default
 	var cfg *sources.Config
 	if *cfgPath == "" {
@@ -70,7 +98,11 @@ func main() {
 			log.Fatalln(err)
 		}
 	}
+	if *permute {
+		cfg.EnablePermutation()
+	}
 	logger := logrus.New()
+	logger.AddHook(sources.RedactHook{})
 	lf := logrus.Fields{
 		"out":        *outPath,
 		"resolve-ip": *resolveIP,
@@ -89,8 +121,40 @@ func main() {
 	if len(*domains) > 0 {
 		lf["domains"] = *domains
 	}
+	if len(cidrs) > 0 {
+		lf["cidrs"] = cidrs
+	}
+	if *deadline > 0 {
+		lf["deadline"] = *deadline
+	}
+	if cfg.Permutation != nil {
+		lf["permute"] = true
+	}
 	logger.WithFields(lf).Info("flag")
 
+	// cancel all the queriers on SIGINT/SIGTERM or when deadline is reached, the results
+	// found before that are still written to output file along with partial statistic
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		<-ctx.Done()
+		select {
+		case <-finished:
+			return
+		default:
+		}
+		// restore default behavior so that the second signal terminates immediately
+		stop()
+		logger.WithError(ctx.Err()).Warn("stop querying, waiting for in-flight queries")
+	}()
+
 	// read input from file(-src=<file path>) or command line(-d=<domain1>,<domain2>)
 	var reader sdfinder.Reader
 	if len(*srcPath) > 0 {
@@ -109,21 +173,36 @@ func main() {
 	if err != nil {
 		log.Fatalf("init err: %v", err)
 	}
-	subdomainFinders.StartWorkers(context.Background())
+	permutation, err := cfg.InitPermutation()
+	if err != nil {
+		log.Fatalf("init permutation err: %v", err)
+	}
+	subdomainFinders.StartWorkers(ctx)
 
 	inChan := make(chan sources.Query)
 	outChan := subdomainFinders.FlattenOutput(
-		subdomainFinders.SendToQueriersAndAggr(context.Background(), inChan),
+		subdomainFinders.SendToQueriersAndAggr(ctx, inChan),
 	)
 	go func() {
+		// cidrs are only sent to queriers that serve CIDR
+		for _, cidr := range cidrs {
+			select {
+			case <-ctx.Done():
+			case inChan <- sources.Query{CIDR: cidr}:
+			}
+		}
+		if reader == nil {
+			close(inChan)
+			return
+		}
 		if err := sdfinder.Read(reader, func(domain string) {
-			if len(domain) == 0 {
+			if len(domain) == 0 || ctx.Err() != nil {
 				return
 			}
 			var queries []sources.Query
 			query := sources.Query{Domain: domain}
 			if *resolveIP {
-				ips, _ := net.LookupIP(domain)
+				ips, _ := net.DefaultResolver.LookupIP(ctx, "ip4", domain)
 				for _, ip := range ips {
 					if ipv4 := ip.To4(); ipv4 != nil {
 						query.IP = ipv4.String()
@@ -134,7 +213,11 @@ func main() {
 				queries = append(queries, query)
 			}
 			for _, qItem := range queries {
-				inChan <- qItem
+				select {
+				case <-ctx.Done():
+					return
+				case inChan <- qItem:
+				}
 			}
 		}, func() {
 			close(inChan)
@@ -151,20 +234,43 @@ func main() {
 	}
 	defer outFile.Close()
 
-	for record := range outChan {
+	write := func(record sources.OutRecord) {
 		out, err := json.Marshal(record)
 		if err != nil {
 			logger.WithField("domain", record.Domain).WithError(err).Error("marshal")
-			continue
+			return
 		}
 		if _, err = outFile.Write(append(out, []byte("\n")...)); err != nil {
 			logger.WithField("domain", record.Domain).WithError(err).Error("write file")
 		}
 	}
+	for record := range outChan {
+		write(record)
+	}
+	// permute subdomains found by all the sources, skipped if it has been canceled
+	if permutation != nil && ctx.Err() == nil {
+		logger.Info("start permutation")
+		for record := range subdomainFinders.Permute(ctx, permutation) {
+			write(record)
+		}
+	}
+	if err := outFile.Sync(); err != nil {
+		logger.WithError(err).Error("flush file")
+	}
+
+	if err := subdomainFinders.Close(); err != nil {
+		logger.WithError(err).Warn("close sources")
+	}
 
 	// collect statistic information and print
 	subdomainFinders.CollectStat()
-	logger.Infof("[unique] domain: %d, subdomain: %d, rows: %d\n",
+	subdomainFinders.MarkPartial(ctx)
+	statPrefix := "[unique]"
+	if subdomainFinders.Stat.Partial {
+		statPrefix = "[partial][unique]"
+	}
+	logger.Infof("%s domain: %d, subdomain: %d, rows: %d\n",
+		statPrefix,
 		subdomainFinders.Stat.DomainsCnt,
 		subdomainFinders.Stat.SubDomainsCnt,
 		subdomainFinders.Stat.TotalOutputRow,
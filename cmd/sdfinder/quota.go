@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shlin168/sdfinder/sources"
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// runQuota prints used and remaining quota of sources in current day and month
+// E.g., ./sdfinder quota -cfg config.yaml
+func runQuota(args []string) {
+	fset := flag.NewFlagSet("quota", flag.ExitOnError)
+	cfgPath := fset.String("cfg", "", "config file path which defines quota of sources")
+	ledgerPath := fset.String("ledger", "", "quota ledger path, overwrite 'quota_ledger' in config. use default if both not given")
+	fset.Parse(args)
+
+	cfg := &sources.Config{SDFinder: make(map[string]sources.SDFinderConfig)}
+	if len(*cfgPath) > 0 {
+		var err error
+		if cfg, err = sources.ReadConfigFromFile(*cfgPath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if len(*ledgerPath) > 0 {
+		cfg.QuotaLedger = *ledgerPath
+	}
+	ledger, err := base.OpenLedger(cfg.LedgerPath())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// sources with quota in config, and sources that have been recorded in ledger
+	uniNames := make(map[string]struct{})
+	for name, sdcfg := range cfg.SDFinder {
+		if sdcfg.Quota.Enabled() {
+			uniNames[name] = struct{}{}
+		}
+	}
+	for _, name := range ledger.Names() {
+		uniNames[name] = struct{}{}
+	}
+	var names []string
+	for name := range uniNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	limitStr := func(limit int) string {
+		if limit <= 0 {
+			return "-"
+		}
+		return strconv.Itoa(limit)
+	}
+	remainStr := func(limit, used int) string {
+		if limit <= 0 {
+			return "-"
+		}
+		return strconv.Itoa(max(limit-used, 0))
+	}
+	fmt.Printf("ledger: %s\n", ledger.Path())
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tPERIOD\tUSED\tLIMIT\tREMAINING")
+	for _, name := range names {
+		var daily, monthly int
+		loc := time.UTC
+		if quota, err := cfg.GetQuota(name); err != nil {
+			log.Fatalln(err)
+		} else if quota != nil {
+			daily, monthly, loc = quota.Daily, quota.Monthly, quota.Location
+		}
+		usage := ledger.Usage(name, time.Now().In(loc))
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", name, usage.Day, usage.DayCnt, limitStr(daily), remainStr(daily, usage.DayCnt))
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", name, usage.Month, usage.MonthCnt, limitStr(monthly), remainStr(monthly, usage.MonthCnt))
+	}
+	w.Flush()
+}
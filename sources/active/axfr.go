@@ -0,0 +1,245 @@
+package active
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// AXFR looks up nameservers of the domain and asks each of them for zone transfer over tcp,
+// owner names in the zone are returned if any nameserver allows the transfer
+const (
+	NameAXFR = "axfr"
+
+	// ParamPort is the param of dns port of nameservers
+	ParamPort = "port"
+
+	DefaultDNSPort = 53
+
+	// AXFRInfoNameserver is the key of extra information, 'host/ip' of nameservers that allow the transfer joined with ','
+	AXFRInfoNameserver = "nameserver"
+)
+
+var (
+	errTransferRefused       = errors.New("zone transfer refused")
+	errNameserverUnreachable = errors.New("nameserver not reachable")
+)
+
+func init() {
+	base.MustRegister(NameAXFR, NewAXFR())
+}
+
+type AXFR struct {
+	base.SDFinder
+	Port     int
+	Resolver *Resolver
+}
+
+func NewAXFR() *AXFR {
+	return &AXFR{SDFinder: *base.NewSDFinder()}
+}
+
+func (ax *AXFR) Init(opts ...base.Option) error {
+	if err := ax.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	var err error
+	if ax.Resolver, err = resolverOf(&ax.SDFinder); err != nil {
+		return err
+	}
+	ax.Port, err = ax.IntParam(ParamPort, DefaultDNSPort)
+	return err
+}
+
+// nameservers returns ips of each nameserver of the domain
+func (ax *AXFR) nameservers(ctx context.Context, domain string) (map[string][]string, error) {
+	lookup := func(do func(context.Context) error) error {
+		return ax.RetryDo(ctx, func() error {
+			if err := ax.RLimiter.Wait(ctx); err != nil {
+				return err
+			}
+			lctx, cancel := context.WithTimeout(ctx, ax.Client.Timeout)
+			defer cancel()
+			return do(lctx)
+		})
+	}
+	var nss []*net.NS
+	err := lookup(func(lctx context.Context) (err error) {
+		nss, err = ax.Resolver.LookupNS(lctx, fqdn(domain))
+		return err
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	nsIPs := make(map[string][]string)
+	for _, ns := range nss {
+		host := strings.ToLower(strings.TrimSuffix(ns.Host, "."))
+		var addrs []net.IPAddr
+		err := lookup(func(lctx context.Context) (err error) {
+			addrs, err = ax.Resolver.LookupIPAddr(lctx, fqdn(host))
+			return err
+		})
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			nsIPs[host] = append(nsIPs[host], addr.IP.String())
+		}
+	}
+	return nsIPs, nil
+}
+
+// Transfer asks the nameserver for zone transfer of the domain, and returns owner names in the zone
+func (ax *AXFR) Transfer(ctx context.Context, addr, domain string) ([]string, error) {
+	if err := ax.RLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	dctx, cancel := context.WithTimeout(ctx, ax.Client.Timeout)
+	defer cancel()
+	conn, err := d.DialContext(dctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNameserverUnreachable, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ax.Client.Timeout))
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	name, err := dnsmessage.NewName(fqdn(domain))
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeAXFR, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]struct{})
+	// the zone starts and ends with SOA record, which might be split into multiple messages
+	for soaCnt := 0; soaCnt < 2; {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf); err != nil {
+			return nil, err
+		}
+		if msg.Header.ID != id {
+			return nil, fmt.Errorf("unexpected message id %d", msg.Header.ID)
+		}
+		if msg.Header.RCode != dnsmessage.RCodeSuccess {
+			return nil, fmt.Errorf("%w: %s", errTransferRefused, msg.Header.RCode)
+		}
+		if len(msg.Answers) == 0 {
+			return nil, errTransferRefused
+		}
+		for _, rr := range msg.Answers {
+			if rr.Header.Type == dnsmessage.TypeSOA {
+				soaCnt++
+			}
+			owner := strings.ToLower(strings.TrimSuffix(rr.Header.Name.String(), "."))
+			if owner != domain && !strings.HasSuffix(owner, "."+domain) {
+				continue
+			}
+			if _, hasseen := seen[owner]; !hasseen {
+				seen[owner] = struct{}{}
+				names = append(names, owner)
+			}
+		}
+	}
+	return names, nil
+}
+
+// GetWithInfo tries zone transfer with every ip of each nameserver, and records 'host/ip' allowing the transfer.
+// Addresses refusing the transfer or not reachable are skipped and counted separately in statistic.
+// The domain is counted in zone transfer statistic if any nameserver allows it
+func (ax *AXFR) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		ax.RecordStat(subdomains, err)
+	}()
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	nsIPs, err := ax.nameservers(ctx, domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	var hosts []string
+	for host := range nsIPs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	allowed := make(map[string][]string) // owner name -> 'host/ip' of nameservers
+	for _, host := range hosts {
+		for _, ip := range nsIPs[host] {
+			names, err := ax.Transfer(ctx, net.JoinHostPort(ip, strconv.Itoa(ax.Port)), domain)
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			if errors.Is(err, errNameserverUnreachable) {
+				atomic.AddUint64(&ax.Stat.NSUnreachableCnt, uint64(1))
+				continue
+			}
+			if err != nil {
+				atomic.AddUint64(&ax.Stat.TransferRefusedCnt, uint64(1))
+				continue
+			}
+			for _, name := range names {
+				if _, exist := allowed[name]; !exist {
+					subdomains = append(subdomains, name)
+				}
+				allowed[name] = append(allowed[name], host+"/"+ip)
+			}
+		}
+	}
+	if len(subdomains) == 0 {
+		return nil, nil, nil
+	}
+	atomic.AddUint64(&ax.Stat.ZoneTransferCnt, uint64(1))
+	info = make(base.ExInfo)
+	for name, nss := range allowed {
+		info.Set(name, AXFRInfoNameserver, strings.Join(nss, ","))
+	}
+	return subdomains, info, nil
+}
+
+func (ax *AXFR) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := ax.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
+
+func (ax *AXFR) RelatedMethod() string {
+	return base.FromActive
+}
+
+func (ax *AXFR) Name() string {
+	return NameAXFR
+}
@@ -0,0 +1,90 @@
+package active
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func newTestZone() []testRecord {
+	return []testRecord{
+		{Name: "bench.com", Type: dnsmessage.TypeSOA, Value: "ns1.bench.com"},
+		{Name: "bench.com", Type: dnsmessage.TypeNS, Value: "ns1.bench.com"},
+		{Name: "bench.com", Type: dnsmessage.TypeNS, Value: "ns2.bench.com"},
+		{Name: "bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.1"},
+		{Name: "ns1.bench.com", Type: dnsmessage.TypeA, Value: "127.0.0.1"},
+		// not reachable since test server only listens on 127.0.0.1
+		{Name: "ns1.bench.com", Type: dnsmessage.TypeAAAA, Value: "::1"},
+		// not reachable
+		{Name: "ns2.bench.com", Type: dnsmessage.TypeAAAA, Value: "100::1"},
+		{Name: "www.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.1"},
+		{Name: "internal.bench.com", Type: dnsmessage.TypeA, Value: "10.0.0.1"},
+		{Name: "vpn.corp.bench.com", Type: dnsmessage.TypeCNAME, Value: "www.bench.com"},
+	}
+}
+
+func TestAXFR(t *testing.T) {
+	srv := (&testDNSServer{Records: newTestZone(), AllowAXFR: true}).start(t)
+	_, port, err := net.SplitHostPort(srv.Addr)
+	require.NoError(t, err)
+
+	ax := NewAXFR()
+	require.NoError(t, ax.Init(
+		base.QPS(1000),
+		base.Timeout(200*time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+		base.Param(ParamPort, port),
+	))
+	assert.Equal(t, base.FromActive, ax.RelatedMethod())
+	subdomains, info, err := ax.GetWithInfo(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"bench.com", "ns1.bench.com", "ns2.bench.com", "www.bench.com", "internal.bench.com", "vpn.corp.bench.com",
+	}, subdomains)
+	assert.Equal(t, "ns1.bench.com/127.0.0.1", info.Get("internal.bench.com", AXFRInfoNameserver))
+	assert.Equal(t, uint64(1), ax.Stat.ZoneTransferCnt)
+	assert.Equal(t, uint64(0), ax.Stat.TransferRefusedCnt)
+	assert.Equal(t, uint64(2), ax.Stat.NSUnreachableCnt)
+	assert.Equal(t, uint64(1), ax.Stat.FoundCnt)
+
+	// domain without nameserver
+	subdomains, err = ax.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, uint64(1), ax.Stat.ZoneTransferCnt)
+	assert.Equal(t, uint64(1), ax.Stat.NotFoundCnt)
+}
+
+func TestAXFRRefused(t *testing.T) {
+	srv := newTestDNSServer(t, newTestZone()...)
+	_, port, err := net.SplitHostPort(srv.Addr)
+	require.NoError(t, err)
+
+	ax := NewAXFR()
+	require.NoError(t, ax.Init(
+		base.QPS(1000),
+		base.Timeout(200*time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+		base.Param(ParamPort, port),
+	))
+	_, err = ax.Transfer(context.Background(), srv.Addr, "bench.com")
+	assert.ErrorIs(t, err, errTransferRefused)
+	_, err = ax.Transfer(context.Background(), net.JoinHostPort("::1", port), "bench.com")
+	assert.ErrorIs(t, err, errNameserverUnreachable)
+	subdomains, err := ax.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, uint64(0), ax.Stat.ZoneTransferCnt)
+	assert.Equal(t, uint64(1), ax.Stat.TransferRefusedCnt)
+	assert.Equal(t, uint64(2), ax.Stat.NSUnreachableCnt)
+	assert.Equal(t, uint64(1), ax.Stat.NotFoundCnt)
+
+	assert.Error(t, NewAXFR().Init(base.Param(ParamPort, "dns")))
+}
@@ -0,0 +1,285 @@
+package active
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// Bruteforce resolves '<word>.<domain>' for each word in wordlist and keeps names that resolve.
+// Random labels are resolved to detect wildcard record of the domain, names resolving to any of their
+// answers are dropped. Workers of the source are the concurrent lookups for one domain
+const (
+	NameBruteforce = "bruteforce"
+
+	// ParamWordlist is the param of wordlist file, one label in each line
+	ParamWordlist = "wordlist"
+
+	// wildcardProbes is the amount of random labels resolved to collect answers of wildcard record, since
+	// round-robin or geo wildcard record returns different answers for each lookup
+	wildcardProbes = 3
+	// DefaultBruteforceQPS is the default rate of lookups, which is shared by all the domains
+	DefaultBruteforceQPS = 20
+
+	// keys of extra information in output
+	BruteforceInfoA     = "a"
+	BruteforceInfoAAAA  = "aaaa"
+	BruteforceInfoCNAME = "cname"
+)
+
+func init() {
+	base.MustRegister(NameBruteforce, NewBruteforce())
+}
+
+type Bruteforce struct {
+	base.SDFinder
+	Words    []string
+	Resolver *Resolver
+}
+
+// Answer is the resolved records of name
+type Answer struct {
+	A     []string
+	AAAA  []string
+	CNAME string // empty if the name has no cname
+}
+
+func (a Answer) Found() bool {
+	return len(a.A) > 0 || len(a.AAAA) > 0
+}
+
+// wildcard is the union of answers of random labels under the domain
+type wildcard map[string]struct{}
+
+func (w wildcard) add(ans Answer) {
+	for _, val := range append(append([]string{ans.CNAME}, ans.A...), ans.AAAA...) {
+		if len(val) > 0 {
+			w[val] = struct{}{}
+		}
+	}
+}
+
+// matches returns whether the answer overlaps with answers of wildcard record
+func (w wildcard) matches(ans Answer) bool {
+	for _, val := range append(append([]string{ans.CNAME}, ans.A...), ans.AAAA...) {
+		if _, exist := w[val]; exist {
+			return true
+		}
+	}
+	return false
+}
+
+func NewBruteforce() *Bruteforce {
+	return &Bruteforce{SDFinder: *base.NewSDFinder()}
+}
+
+func (bf *Bruteforce) Init(opts ...base.Option) error {
+	if err := bf.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	var err error
+	if bf.Resolver, err = resolverOf(&bf.SDFinder); err != nil {
+		return err
+	}
+	wordlist := bf.StringParam(ParamWordlist, "")
+	if len(wordlist) == 0 {
+		return fmt.Errorf("param %q is required for %s", ParamWordlist, NameBruteforce)
+	}
+	bf.Words, err = ReadWordlist(wordlist)
+	return err
+}
+
+// ReadWordlist reads unique labels from file, empty lines and lines starting with '#' are skipped
+func ReadWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open wordlist error: %v", err)
+	}
+	defer f.Close()
+	var words []string
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.Trim(strings.TrimSpace(scanner.Text()), "."))
+		if _, hasseen := seen[word]; len(word) == 0 || strings.HasPrefix(word, "#") || hasseen {
+			continue
+		}
+		seen[word] = struct{}{}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read wordlist error: %v", err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty wordlist %q", path)
+	}
+	return words, nil
+}
+
+// Resolve looks up A, AAAA and CNAME of name, the name which does not exist is not an error.
+// Each lookup waits for rate limiter and is retried base on retry policy
+func (bf *Bruteforce) Resolve(ctx context.Context, name string) (ans Answer, err error) {
+	err = bf.RetryDo(ctx, func() error {
+		if err := bf.RLimiter.Wait(ctx); err != nil {
+			return err
+		}
+		lctx, cancel := context.WithTimeout(ctx, bf.Client.Timeout)
+		defer cancel()
+		ans = Answer{}
+		addrs, err := bf.Resolver.LookupIPAddr(lctx, fqdn(name))
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		for _, addr := range addrs {
+			if addr.IP.To4() != nil {
+				ans.A = append(ans.A, addr.IP.String())
+			} else {
+				ans.AAAA = append(ans.AAAA, addr.IP.String())
+			}
+		}
+		sort.Strings(ans.A)
+		sort.Strings(ans.AAAA)
+		cname, err := bf.Resolver.LookupCNAME(lctx, fqdn(name))
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		if cname = strings.ToLower(strings.TrimSuffix(cname, ".")); len(cname) > 0 && cname != name {
+			ans.CNAME = cname
+		}
+		return nil
+	})
+	return ans, err
+}
+
+// randomLabel is used to detect wildcard record of the domain
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "sdfinder-" + hex.EncodeToString(b)
+}
+
+// GetWithInfo resolves '<word>.<domain>' for each word in wordlist
+func (bf *Bruteforce) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		bf.RecordStat(subdomains, err)
+	}()
+	domain = strings.ToLower(domain)
+	names := make([]string, 0, len(bf.Words))
+	for _, word := range bf.Words {
+		names = append(names, word+"."+domain)
+	}
+	return bf.resolveAll(ctx, domain, names)
+}
+
+// detectWildcard resolves random labels under the domain and returns the union of answers,
+// which is empty if the domain has no wildcard record
+func (bf *Bruteforce) detectWildcard(ctx context.Context, domain string) (wildcard, error) {
+	w := make(wildcard)
+	for i := 0; i < wildcardProbes; i++ {
+		ans, err := bf.Resolve(ctx, randomLabel()+"."+domain)
+		if err != nil {
+			return nil, err
+		}
+		if !ans.Found() {
+			// no wildcard record if random label does not resolve
+			return w, nil
+		}
+		w.add(ans)
+	}
+	return w, nil
+}
+
+// resolveAll resolves candidates under the domain concurrently, lookups failing after retries are skipped
+// and counted in statistic, and the error is returned only if all the lookups fail
+func (bf *Bruteforce) resolveAll(ctx context.Context, domain string, names []string) (subdomains []string, info base.ExInfo, err error) {
+	wildcard, err := bf.detectWildcard(ctx, domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates := make(chan string)
+	go func() {
+		defer close(candidates)
+		for _, name := range names {
+			select {
+			case candidates <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failed  int
+		lastErr error
+	)
+	info = make(base.ExInfo)
+	for i := 0; i < bf.Workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range candidates {
+				ans, err := bf.Resolve(ctx, name)
+				mu.Lock()
+				switch {
+				case err != nil:
+					failed, lastErr = failed+1, err
+					if ctx.Err() == nil {
+						atomic.AddUint64(&bf.Stat.LookupErrCnt, uint64(1))
+					}
+				case ans.Found() && !wildcard.matches(ans):
+					subdomains = append(subdomains, name)
+					setAnswer(info, name, ans)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if failed > 0 && failed == len(names) {
+		return nil, nil, lastErr
+	}
+	sort.Strings(subdomains)
+	return subdomains, info, nil
+}
+
+func setAnswer(info base.ExInfo, name string, ans Answer) {
+	if len(ans.A) > 0 {
+		info.Set(name, BruteforceInfoA, strings.Join(ans.A, ","))
+	}
+	if len(ans.AAAA) > 0 {
+		info.Set(name, BruteforceInfoAAAA, strings.Join(ans.AAAA, ","))
+	}
+	if len(ans.CNAME) > 0 {
+		info.Set(name, BruteforceInfoCNAME, ans.CNAME)
+	}
+}
+
+func (bf *Bruteforce) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := bf.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
+
+func (bf *Bruteforce) RelatedMethod() string {
+	return base.FromActive
+}
+
+func (bf *Bruteforce) Name() string {
+	return NameBruteforce
+}
@@ -0,0 +1,133 @@
+package active
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func writeWordlist(t *testing.T, words ...string) string {
+	path := filepath.Join(t.TempDir(), "wordlist.txt")
+	require.NoError(t, os.WriteFile(path, []byte(joinLines(words...)), 0644))
+	return path
+}
+
+func joinLines(lines ...string) string {
+	var content string
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	return content
+}
+
+func TestReadWordlist(t *testing.T) {
+	words, err := ReadWordlist(writeWordlist(t, "www", "# comment", "", " Mail ", "www", "api."))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www", "mail", "api"}, words)
+	_, err = ReadWordlist(writeWordlist(t, "# comment"))
+	assert.Error(t, err)
+	_, err = ReadWordlist(filepath.Join(t.TempDir(), "not-exist.txt"))
+	assert.Error(t, err)
+}
+
+func TestBruteforce(t *testing.T) {
+	srv := (&testDNSServer{FailName: "v6.bench.com", FailCnt: 1, Records: []testRecord{
+		{Name: "bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.1"},
+		{Name: "www.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.1"},
+		{Name: "www.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.2"},
+		{Name: "www.bench.com", Type: dnsmessage.TypeAAAA, Value: "2001:db8::1"},
+		{Name: "cdn.bench.com", Type: dnsmessage.TypeCNAME, Value: "bench.cdn.net"},
+		{Name: "bench.cdn.net", Type: dnsmessage.TypeA, Value: "2.2.2.2"},
+		{Name: "v6.bench.com", Type: dnsmessage.TypeAAAA, Value: "2001:db8::2"},
+		{Name: "txt.bench.com", Type: dnsmessage.TypeTXT, Value: "no address"},
+		// wildcard domain
+		{Name: "*.wild.com", Type: dnsmessage.TypeA, Value: "9.9.9.9"},
+		{Name: "www.wild.com", Type: dnsmessage.TypeA, Value: "3.3.3.3"},
+	}}).start(t)
+
+	bf := NewBruteforce()
+	require.NoError(t, bf.Init(
+		base.QPS(1000),
+		base.Timeout(time.Second),
+		base.Retries(1, time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+		base.Param(ParamWordlist, writeWordlist(t, "www", "cdn", "v6", "txt", "notexist")),
+		base.Worker(3),
+	))
+	assert.Equal(t, base.FromActive, bf.RelatedMethod())
+	subdomains, info, err := bf.GetWithInfo(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cdn.bench.com", "v6.bench.com", "www.bench.com"}, subdomains)
+	assert.Equal(t, map[string]string{
+		BruteforceInfoA:    "1.1.1.1,1.1.1.2",
+		BruteforceInfoAAAA: "2001:db8::1",
+	}, info["www.bench.com"])
+	assert.Equal(t, map[string]string{
+		BruteforceInfoA:     "2.2.2.2",
+		BruteforceInfoCNAME: "bench.cdn.net",
+	}, info["cdn.bench.com"])
+	assert.Equal(t, "2001:db8::2", info.Get("v6.bench.com", BruteforceInfoAAAA))
+	assert.Equal(t, uint64(1), bf.Stat.SuccessCnt)
+	assert.Equal(t, uint64(3), bf.Stat.RelatedDomainCnt)
+
+	// names resolving to wildcard record are dropped
+	subdomains, err = bf.Get(context.Background(), "wild.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.wild.com"}, subdomains)
+}
+
+func TestBruteforceRoundRobinWildcard(t *testing.T) {
+	srv := (&testDNSServer{RoundRobin: true, FailName: "txt.wild.com", FailCnt: 100, Records: []testRecord{
+		{Name: "*.wild.com", Type: dnsmessage.TypeA, Value: "9.9.9.1"},
+		{Name: "*.wild.com", Type: dnsmessage.TypeA, Value: "9.9.9.2"},
+		{Name: "*.wild.com", Type: dnsmessage.TypeA, Value: "9.9.9.3"},
+		{Name: "www.wild.com", Type: dnsmessage.TypeA, Value: "3.3.3.3"},
+	}}).start(t)
+
+	bf := NewBruteforce()
+	require.NoError(t, bf.Init(
+		base.QPS(1000),
+		base.Timeout(time.Second),
+		base.Retries(1, time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+		base.Param(ParamWordlist, writeWordlist(t, "www", "cdn", "v6", "txt", "notexist")),
+		base.Worker(3),
+	))
+	// each lookup under the wildcard returns one of the records, the failed lookup is counted
+	subdomains, err := bf.Get(context.Background(), "wild.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.wild.com"}, subdomains)
+	assert.Equal(t, uint64(1), bf.Stat.LookupErrCnt)
+}
+
+func TestBruteforceError(t *testing.T) {
+	wordlist := writeWordlist(t, "www")
+	assert.Error(t, NewBruteforce().Init())
+	assert.Error(t, NewBruteforce().Init(base.Param(ParamWordlist, wordlist), base.Param(ParamResolvers, "dns.google")))
+
+	// resolver is not reachable
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	pc.Close()
+	bf := NewBruteforce()
+	require.NoError(t, bf.Init(base.Timeout(100*time.Millisecond), base.Param(ParamWordlist, wordlist),
+		base.Param(ParamResolvers, pc.LocalAddr().String())))
+	_, err = bf.Get(context.Background(), "bench.com")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), bf.Stat.ErrCnt)
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bf.Get(ctx, "bench.com")
+	assert.ErrorIs(t, err, base.ErrCanceled)
+}
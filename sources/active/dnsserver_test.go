@@ -0,0 +1,239 @@
+package active
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type testRecord struct {
+	Name  string
+	Type  dnsmessage.Type
+	Value string
+}
+
+// testDNSServer answers queries of records over udp and tcp on the same port,
+// '*.<domain>' is the wildcard record of the domain
+type testDNSServer struct {
+	Addr    string
+	Records []testRecord
+	Queries int32
+	// answer SERVFAIL for the name in the first n queries
+	FailName string
+	FailCnt  int32
+	// allow zone transfer over tcp
+	AllowAXFR bool
+	// answer one of the wildcard records in turn, like round-robin or geo wildcard
+	RoundRobin bool
+	turn       uint32
+}
+
+func newTestDNSServer(t *testing.T, records ...testRecord) *testDNSServer {
+	return (&testDNSServer{Records: records}).start(t)
+}
+
+// start serves on a random port, fields should be set before start since they are read by the server
+func (srv *testDNSServer) start(t *testing.T) *testDNSServer {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv.Addr = pc.LocalAddr().String()
+	ln, err := net.Listen("tcp", srv.Addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		pc.Close()
+		ln.Close()
+	})
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if rsp := srv.handle(buf[:n]); rsp != nil {
+				pc.WriteTo(rsp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serveTCP(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *testDNSServer) serveTCP(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		for _, rsp := range srv.handleTCP(buf) {
+			conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(rsp))), rsp...))
+		}
+	}
+}
+
+// handleTCP answers the query, or the zone in two messages if it's zone transfer
+func (srv *testDNSServer) handleTCP(query []byte) [][]byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	if q.Type != dnsmessage.TypeAXFR {
+		if rsp := srv.handle(query); rsp != nil {
+			return [][]byte{rsp}
+		}
+		return nil
+	}
+	if !srv.AllowAXFR {
+		return [][]byte{buildTestResponse(h.ID, q, dnsmessage.RCodeRefused, nil)}
+	}
+	zone := strings.TrimSuffix(q.Name.String(), ".")
+	var soa []testRecord
+	var records []testRecord
+	for _, rec := range srv.Records {
+		if rec.Name == zone && rec.Type == dnsmessage.TypeSOA {
+			soa = append(soa, rec)
+		} else if rec.Name == zone || strings.HasSuffix(rec.Name, "."+zone) {
+			records = append(records, rec)
+		}
+	}
+	half := len(records) / 2
+	return [][]byte{
+		buildTestResponse(h.ID, q, dnsmessage.RCodeSuccess, append(soa, records[:half]...)),
+		buildTestResponse(h.ID, q, dnsmessage.RCodeSuccess, append(records[half:], soa...)),
+	}
+}
+
+func (srv *testDNSServer) lookup(name string, qtype dnsmessage.Type) (records []testRecord, exist bool) {
+	for _, rec := range srv.Records {
+		if rec.Name != name {
+			continue
+		}
+		exist = true
+		if rec.Type == qtype {
+			records = append(records, rec)
+		}
+	}
+	if !exist {
+		if idx := strings.Index(name, "."); idx > 0 {
+			for _, rec := range srv.lookupWildcard("*"+name[idx:], qtype) {
+				rec.Name = name
+				records = append(records, rec)
+				exist = true
+			}
+		}
+	}
+	return records, exist
+}
+
+func (srv *testDNSServer) lookupWildcard(name string, qtype dnsmessage.Type) []testRecord {
+	var records []testRecord
+	for _, rec := range srv.Records {
+		if rec.Name == name && (rec.Type == qtype || rec.Type == dnsmessage.TypeCNAME) {
+			records = append(records, rec)
+		}
+	}
+	if srv.RoundRobin && len(records) > 1 {
+		i := int(atomic.AddUint32(&srv.turn, 1)) % len(records)
+		return records[i : i+1]
+	}
+	return records
+}
+
+func (srv *testDNSServer) answer(q dnsmessage.Question) ([]testRecord, dnsmessage.RCode) {
+	name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+	if srv.FailName == name && atomic.AddInt32(&srv.FailCnt, -1) >= 0 {
+		return nil, dnsmessage.RCodeServerFailure
+	}
+	var answers []testRecord
+	for i := 0; i < 8; i++ {
+		cnames, exist := srv.lookup(name, dnsmessage.TypeCNAME)
+		if !exist {
+			if i == 0 {
+				return nil, dnsmessage.RCodeNameError
+			}
+			break
+		}
+		if q.Type == dnsmessage.TypeCNAME || len(cnames) == 0 {
+			records, _ := srv.lookup(name, q.Type)
+			return append(answers, records...), dnsmessage.RCodeSuccess
+		}
+		answers = append(answers, cnames[0])
+		name = cnames[0].Value
+	}
+	return answers, dnsmessage.RCodeSuccess
+}
+
+func (srv *testDNSServer) handle(query []byte) []byte {
+	atomic.AddInt32(&srv.Queries, 1)
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	answers, rcode := srv.answer(q)
+	return buildTestResponse(h.ID, q, rcode, answers)
+}
+
+func buildTestResponse(id uint16, q dnsmessage.Question, rcode dnsmessage.RCode, answers []testRecord) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID: id, Response: true, Authoritative: true, RecursionAvailable: true, RCode: rcode,
+	})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	for _, rec := range answers {
+		rh := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(rec.Name + "."), Class: dnsmessage.ClassINET, TTL: 60}
+		switch rec.Type {
+		case dnsmessage.TypeA:
+			b.AResource(rh, dnsmessage.AResource{A: netip.MustParseAddr(rec.Value).As4()})
+		case dnsmessage.TypeAAAA:
+			b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: netip.MustParseAddr(rec.Value).As16()})
+		case dnsmessage.TypeCNAME:
+			b.CNAMEResource(rh, dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(rec.Value + ".")})
+		case dnsmessage.TypeNS:
+			b.NSResource(rh, dnsmessage.NSResource{NS: dnsmessage.MustNewName(rec.Value + ".")})
+		case dnsmessage.TypePTR:
+			b.PTRResource(rh, dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(rec.Value + ".")})
+		case dnsmessage.TypeSOA:
+			b.SOAResource(rh, dnsmessage.SOAResource{
+				NS: dnsmessage.MustNewName(rec.Value + "."), MBox: dnsmessage.MustNewName("admin." + rec.Name + "."),
+				Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, MinTTL: 60,
+			})
+		}
+	}
+	rsp, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return rsp
+}
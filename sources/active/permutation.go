@@ -0,0 +1,239 @@
+package active
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// Permutation generates altdns-style variants of subdomains found by other sources and keeps variants
+// that resolve. It's not a source registered in SDFinderMap, but a stage after all the sources finish,
+// which is seeded with the subdomains found under each domain
+const (
+	NamePermutation = "permutation"
+
+	// ParamMaxCandidates is the param of maximum variants to resolve for one domain, the rest are skipped
+	ParamMaxCandidates = "max_candidates"
+
+	DefaultPermutationMaxCandidates = 5000
+)
+
+var (
+	// PermutationEnvs are environment tokens swapped with each other, E.g., 'api-dev' -> 'api-stg'
+	PermutationEnvs = []string{"dev", "stg", "stage", "staging", "test", "qa", "uat", "prod"}
+	// DefaultPermutationWords are used if wordlist is not given
+	DefaultPermutationWords = []string{
+		"dev", "stg", "staging", "test", "qa", "uat", "prod",
+		"api", "admin", "internal", "beta", "new", "old", "v1", "v2",
+	}
+
+	numberPattern = regexp.MustCompile(`[0-9]+`)
+	labelPattern  = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?$`)
+)
+
+type Permutation struct {
+	Bruteforce
+	MaxCandidates int
+
+	mu    sync.Mutex
+	known map[string]map[string]struct{} // domain -> subdomains found under the domain
+}
+
+func NewPermutation() *Permutation {
+	return &Permutation{
+		Bruteforce: Bruteforce{SDFinder: *base.NewSDFinder()},
+		known:      make(map[string]map[string]struct{}),
+	}
+}
+
+func (p *Permutation) Init(opts ...base.Option) error {
+	if err := p.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	var err error
+	if p.Resolver, err = resolverOf(&p.SDFinder); err != nil {
+		return err
+	}
+	if p.MaxCandidates, err = p.IntParam(ParamMaxCandidates, DefaultPermutationMaxCandidates); err != nil {
+		return err
+	}
+	p.Words = DefaultPermutationWords
+	if wordlist := p.StringParam(ParamWordlist, ""); len(wordlist) > 0 {
+		p.Words, err = ReadWordlist(wordlist)
+	}
+	return err
+}
+
+// Seed adds subdomains found under the domain, which are permuted in Get and never returned again
+func (p *Permutation) Seed(domain string, subdomains ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	domain = strings.ToLower(domain)
+	if _, exist := p.known[domain]; !exist {
+		p.known[domain] = make(map[string]struct{})
+	}
+	for _, subdomain := range subdomains {
+		p.known[domain][strings.ToLower(subdomain)] = struct{}{}
+	}
+}
+
+// Candidates returns at most max candidates variants of the seeded subdomains, along with
+// the total amount of variants. Variants which have been seeded are excluded
+func (p *Permutation) Candidates(domain string) (candidates []string, total int) {
+	p.mu.Lock()
+	var subdomains []string
+	known := make(map[string]struct{}, len(p.known[domain]))
+	for subdomain := range p.known[domain] {
+		subdomains = append(subdomains, subdomain)
+		known[subdomain] = struct{}{}
+	}
+	p.mu.Unlock()
+	sort.Strings(subdomains)
+	seen := make(map[string]struct{})
+	for _, subdomain := range subdomains {
+		for _, variant := range Permute(subdomain, domain, p.Words) {
+			if _, hasseen := seen[variant]; hasseen {
+				continue
+			}
+			seen[variant] = struct{}{}
+			if _, exist := known[variant]; exist {
+				continue
+			}
+			if total++; len(candidates) < p.MaxCandidates {
+				candidates = append(candidates, variant)
+			}
+		}
+	}
+	return candidates, total
+}
+
+// Permute returns altdns-style variants of the subdomain under the domain, E.g., 'api-v2.abc.com' with
+// word 'dev' generates
+//   - word inserted as label: 'dev.api-v2.abc.com', 'api-v2.dev.abc.com'
+//   - word joined to label: 'api-v2-dev.abc.com', 'dev-api-v2.abc.com', 'api-v2dev.abc.com', 'devapi-v2.abc.com'
+//   - number incremented and decremented: 'api-v3.abc.com', 'api-v1.abc.com'
+//   - dash and dot swapped: 'api.v2.abc.com'
+//   - environment token swapped: 'api-stg.abc.com' from 'api-dev.abc.com'
+//
+// Variants with invalid label are dropped
+func Permute(subdomain, domain string, words []string) []string {
+	prefix := strings.TrimSuffix(subdomain, "."+domain)
+	if prefix == subdomain || len(prefix) == 0 {
+		return nil
+	}
+	labels := strings.Split(prefix, ".")
+	var variants []string
+	add := func(ls ...string) {
+		for _, label := range ls {
+			if !labelPattern.MatchString(label) {
+				return
+			}
+		}
+		variants = append(variants, strings.Join(ls, ".")+"."+domain)
+	}
+	// replaced returns labels with labels[i] replaced by given labels
+	replaced := func(i int, ls ...string) []string {
+		result := append([]string{}, labels[:i]...)
+		result = append(result, ls...)
+		return append(result, labels[i+1:]...)
+	}
+	for i := 0; i <= len(labels); i++ {
+		for _, word := range words {
+			result := append([]string{}, labels[:i]...)
+			result = append(result, word)
+			add(append(result, labels[i:]...)...)
+		}
+	}
+	for i, label := range labels {
+		for _, word := range words {
+			add(replaced(i, label+"-"+word)...)
+			add(replaced(i, word+"-"+label)...)
+			add(replaced(i, label+word)...)
+			add(replaced(i, word+label)...)
+		}
+		for _, num := range incNumbers(label) {
+			add(replaced(i, num)...)
+		}
+		parts := strings.Split(label, "-")
+		for j := 1; j < len(parts); j++ {
+			add(replaced(i, strings.Join(parts[:j], "-"), strings.Join(parts[j:], "-"))...)
+		}
+		if i+1 < len(labels) {
+			result := append([]string{}, labels[:i]...)
+			result = append(result, label+"-"+labels[i+1])
+			add(append(result, labels[i+2:]...)...)
+		}
+		for j, part := range parts {
+			if !isEnv(part) {
+				continue
+			}
+			for _, env := range PermutationEnvs {
+				if env != part {
+					swapped := append([]string{}, parts...)
+					swapped[j] = env
+					add(replaced(i, strings.Join(swapped, "-"))...)
+				}
+			}
+		}
+	}
+	return variants
+}
+
+// incNumbers returns labels with each number in the label increased and decreased by one,
+// the width of number with leading zeros is kept, E.g., 'web01' -> 'web02', 'web00'
+func incNumbers(label string) []string {
+	var labels []string
+	for _, loc := range numberPattern.FindAllStringIndex(label, -1) {
+		num, err := strconv.Atoi(label[loc[0]:loc[1]])
+		if err != nil {
+			continue
+		}
+		for _, n := range []int{num - 1, num + 1} {
+			if n < 0 {
+				continue
+			}
+			labels = append(labels, label[:loc[0]]+fmt.Sprintf("%0*d", loc[1]-loc[0], n)+label[loc[1]:])
+		}
+	}
+	return labels
+}
+
+func isEnv(token string) bool {
+	for _, env := range PermutationEnvs {
+		if env == token {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWithInfo resolves the variants of subdomains seeded under the domain. Variants exceeding max candidates
+// are skipped and counted as limit skip in statistic
+func (p *Permutation) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		p.RecordStat(subdomains, err)
+	}()
+	domain = strings.ToLower(domain)
+	candidates, total := p.Candidates(domain)
+	p.RecordLimitSkip(uint64(total - len(candidates)))
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+	return p.resolveAll(ctx, domain, candidates)
+}
+
+func (p *Permutation) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := p.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
+
+func (p *Permutation) Name() string {
+	return NamePermutation
+}
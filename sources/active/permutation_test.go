@@ -0,0 +1,97 @@
+package active
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func TestPermute(t *testing.T) {
+	variants := Permute("api-v2.x.com", "x.com", []string{"dev"})
+	for _, exp := range []string{
+		"dev.api-v2.x.com", "api-v2.dev.x.com", // insert
+		"api-v2-dev.x.com", "dev-api-v2.x.com", "api-v2dev.x.com", "devapi-v2.x.com", // append
+		"api-v3.x.com", "api-v1.x.com", // number
+		"api.v2.x.com", // dash to dot
+	} {
+		assert.Contains(t, variants, exp)
+	}
+	for _, variant := range variants {
+		assert.NotEqual(t, "api-v2.x.com", variant)
+	}
+
+	variants = Permute("web.dev.x.com", "x.com", nil)
+	assert.Contains(t, variants, "web-dev.x.com")  // dot to dash
+	assert.Contains(t, variants, "web.stg.x.com")  // env
+	assert.Contains(t, variants, "web.prod.x.com") // env
+
+	variants = Permute("api-dev.x.com", "x.com", nil)
+	assert.Contains(t, variants, "api-staging.x.com")
+
+	// invalid label
+	assert.NotContains(t, Permute("api.x.com", "x.com", []string{"-"}), "api--.x.com")
+	assert.Empty(t, Permute("x.com", "x.com", []string{"dev"}))
+	assert.Empty(t, Permute("api.y.com", "x.com", []string{"dev"}))
+
+	assert.Equal(t, []string{"web00", "web02"}, incNumbers("web01"))
+	assert.Equal(t, []string{"v1"}, incNumbers("v0"))
+	assert.Equal(t, []string{"a0b1", "a2b1", "a1b0", "a1b2"}, incNumbers("a1b1"))
+	assert.Empty(t, incNumbers("www"))
+}
+
+func TestPermutationCandidates(t *testing.T) {
+	p := NewPermutation()
+	require.NoError(t, p.Init(base.Param(ParamMaxCandidates, "3")))
+	assert.Equal(t, DefaultPermutationWords, p.Words)
+	p.Seed("x.com", "api-v2.x.com", "API-V3.x.com")
+	candidates, total := p.Candidates("x.com")
+	assert.Len(t, candidates, 3)
+	assert.Greater(t, total, 3)
+
+	p.MaxCandidates = total
+	candidates, _ = p.Candidates("x.com")
+	assert.Contains(t, candidates, "api-v4.x.com")
+	assert.Contains(t, candidates, "api-v1.x.com")
+	// seeded subdomains are excluded
+	assert.NotContains(t, candidates, "api-v3.x.com")
+	assert.NotContains(t, candidates, "api-v2.x.com")
+
+	candidates, total = p.Candidates("y.com")
+	assert.Empty(t, candidates)
+	assert.Equal(t, 0, total)
+}
+
+func TestPermutation(t *testing.T) {
+	srv := newTestDNSServer(t,
+		testRecord{Name: "api-v2.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.1"},
+		testRecord{Name: "api-v3.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.2"},
+		testRecord{Name: "api-v2.stg.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.3"},
+		testRecord{Name: "api-v2.dev.bench.com", Type: dnsmessage.TypeA, Value: "1.1.1.4"},
+	)
+	p := NewPermutation()
+	require.NoError(t, p.Init(
+		base.QPS(1000),
+		base.Timeout(200*time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+		base.Worker(5),
+	))
+	assert.Equal(t, "active/permutation", p.RelatedMethod()+"/"+p.Name())
+	p.Seed("bench.com", "api-v2.bench.com", "api-v2.dev.bench.com")
+	subdomains, info, err := p.GetWithInfo(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api-v2.stg.bench.com", "api-v3.bench.com"}, subdomains)
+	assert.Equal(t, "1.1.1.2", info.Get("api-v3.bench.com", BruteforceInfoA))
+	assert.Equal(t, uint64(1), p.Stat.FoundCnt)
+
+	// nothing seeded
+	subdomains, err = p.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, uint64(1), p.Stat.NotFoundCnt)
+}
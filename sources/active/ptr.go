@@ -0,0 +1,201 @@
+package active
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// PTR sweeps every address in the cidr with reverse lookup, hostnames of the addresses are returned.
+// The cidr is either given by input or the netblock around the resolved ip of domain.
+// Workers of the source are the concurrent lookups for one cidr
+const (
+	NamePTR = "ptr"
+
+	// ParamMaxHosts is the param of maximum addresses to sweep in one cidr, the rest are skipped
+	ParamMaxHosts = "max_hosts"
+
+	DefaultPTRMaxHosts = 256
+	// DefaultPTRQPS is the default rate of lookups, which is shared by all the cidrs
+	DefaultPTRQPS = 20
+
+	// PTRInfoIP is the key of extra information, addresses pointing to the hostname joined with ','
+	PTRInfoIP = "ip"
+)
+
+func init() {
+	base.MustRegister(NamePTR, NewPTR())
+}
+
+type PTR struct {
+	base.SDFinder
+	MaxHosts int
+	Resolver *Resolver
+}
+
+func NewPTR() *PTR {
+	return &PTR{SDFinder: *base.NewSDFinder()}
+}
+
+func (p *PTR) Init(opts ...base.Option) error {
+	if err := p.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	var err error
+	if p.Resolver, err = resolverOf(&p.SDFinder); err != nil {
+		return err
+	}
+	p.MaxHosts, err = p.IntParam(ParamMaxHosts, DefaultPTRMaxHosts)
+	return err
+}
+
+// ParseCIDR parses cidr or single ip, which is treated as cidr with only one address
+func ParseCIDR(cidr string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(cidr); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid cidr %q", cidr)
+	}
+	return prefix.Masked(), nil
+}
+
+// Hosts returns at most limit addresses in the cidr from the first one, along with
+// the amount of addresses skipped, which is capped to max uint64 for large ipv6 cidr
+func Hosts(prefix netip.Prefix, limit int) (hosts []netip.Addr, skipped uint64) {
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr) && len(hosts) < limit; addr = addr.Next() {
+		hosts = append(hosts, addr)
+	}
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits >= 64 {
+		return hosts, math.MaxUint64
+	}
+	return hosts, 1<<hostBits - uint64(len(hosts))
+}
+
+// Lookup returns hostnames of the address, the address without ptr record is not an error.
+// Each lookup waits for rate limiter and is retried base on retry policy
+func (p *PTR) Lookup(ctx context.Context, addr netip.Addr) (names []string, err error) {
+	err = p.RetryDo(ctx, func() error {
+		if err := p.RLimiter.Wait(ctx); err != nil {
+			return err
+		}
+		lctx, cancel := context.WithTimeout(ctx, p.Client.Timeout)
+		defer cancel()
+		names = nil
+		ptrs, err := p.Resolver.LookupAddr(lctx, addr.String())
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		for _, ptr := range ptrs {
+			if name := strings.ToLower(strings.TrimSuffix(ptr, ".")); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+// GetWithInfo looks up addresses in the cidr concurrently, lookups failing after retries are skipped,
+// and the error is returned only if all the lookups fail. Addresses exceeding max hosts are skipped
+// and counted as limit skip in statistic
+func (p *PTR) GetWithInfo(ctx context.Context, cidr string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		p.RecordStat(subdomains, err)
+	}()
+	prefix, err := ParseCIDR(cidr)
+	if err != nil {
+		return nil, nil, err
+	}
+	hosts, skipped := Hosts(prefix, p.MaxHosts)
+	p.RecordLimitSkip(skipped)
+	addrs := make(chan netip.Addr)
+	go func() {
+		defer close(addrs)
+		for _, addr := range hosts {
+			select {
+			case addrs <- addr:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failed  int
+		lastErr error
+	)
+	hostIPs := make(map[string][]string) // hostname -> addresses
+	for i := 0; i < p.Workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range addrs {
+				names, err := p.Lookup(ctx, addr)
+				mu.Lock()
+				if err != nil {
+					failed, lastErr = failed+1, err
+					if ctx.Err() == nil {
+						atomic.AddUint64(&p.Stat.LookupErrCnt, uint64(1))
+					}
+				}
+				for _, name := range names {
+					hostIPs[name] = append(hostIPs[name], addr.String())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if failed == len(hosts) {
+		return nil, nil, lastErr
+	}
+	info = make(base.ExInfo)
+	for name, ips := range hostIPs {
+		sort.Slice(ips, func(i, j int) bool {
+			return netip.MustParseAddr(ips[i]).Less(netip.MustParseAddr(ips[j]))
+		})
+		subdomains = append(subdomains, name)
+		info.Set(name, PTRInfoIP, strings.Join(ips, ","))
+	}
+	sort.Strings(subdomains)
+	return subdomains, info, nil
+}
+
+func (p *PTR) Get(ctx context.Context, cidr string) ([]string, error) {
+	subdomains, _, err := p.GetWithInfo(ctx, cidr)
+	return subdomains, err
+}
+
+func (p *PTR) ServeType() base.InputType {
+	return base.InputCIDR
+}
+
+func (p *PTR) RelatedType() string {
+	return base.RLPRvsDNS
+}
+
+func (p *PTR) RelatedMethod() string {
+	return base.FromActive
+}
+
+func (p *PTR) Name() string {
+	return NamePTR
+}
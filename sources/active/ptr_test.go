@@ -0,0 +1,107 @@
+package active
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func TestHosts(t *testing.T) {
+	hosts, skipped := Hosts(netip.MustParsePrefix("10.0.0.0/30"), 256)
+	assert.Equal(t, uint64(0), skipped)
+	assert.Equal(t, []netip.Addr{
+		netip.MustParseAddr("10.0.0.0"),
+		netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("10.0.0.2"),
+		netip.MustParseAddr("10.0.0.3"),
+	}, hosts)
+
+	hosts, skipped = Hosts(netip.MustParsePrefix("10.0.0.0/8"), 2)
+	assert.Equal(t, uint64(1<<24-2), skipped)
+	assert.Len(t, hosts, 2)
+
+	hosts, skipped = Hosts(netip.MustParsePrefix("2001:db8::/32"), 1)
+	assert.Equal(t, uint64(math.MaxUint64), skipped)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("2001:db8::")}, hosts)
+
+	prefix, err := ParseCIDR("10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1/32", prefix.String())
+	prefix, err = ParseCIDR("10.0.0.1/24")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/24", prefix.String())
+	_, err = ParseCIDR("abc.com")
+	assert.Error(t, err)
+}
+
+func TestPTR(t *testing.T) {
+	srv := newTestDNSServer(t,
+		testRecord{Name: "1.0.0.10.in-addr.arpa", Type: dnsmessage.TypePTR, Value: "web.bench.com"},
+		testRecord{Name: "2.0.0.10.in-addr.arpa", Type: dnsmessage.TypePTR, Value: "WEB.bench.com"},
+		testRecord{Name: "2.0.0.10.in-addr.arpa", Type: dnsmessage.TypePTR, Value: "mail.other.com"},
+	)
+	ptr := NewPTR()
+	require.NoError(t, ptr.Init(
+		base.QPS(1000),
+		base.Timeout(200*time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+	))
+	assert.Equal(t, base.InputCIDR, ptr.ServeType())
+	assert.Equal(t, base.RLPRvsDNS, ptr.RelatedType())
+	assert.Equal(t, DefaultPTRMaxHosts, ptr.MaxHosts)
+
+	subdomains, info, err := ptr.GetWithInfo(context.Background(), "10.0.0.0/30")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail.other.com", "web.bench.com"}, subdomains)
+	assert.Equal(t, "10.0.0.1,10.0.0.2", info.Get("web.bench.com", PTRInfoIP))
+	assert.Equal(t, "10.0.0.2", info.Get("mail.other.com", PTRInfoIP))
+	assert.Equal(t, uint64(0), ptr.Stat.LimitSkipCnt)
+	assert.Equal(t, uint64(0), ptr.Stat.ReportedCnt)
+
+	// no ptr record
+	subdomains, err = ptr.Get(context.Background(), "10.0.1.0/30")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+
+	_, err = ptr.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), ptr.Stat.FoundCnt)
+	assert.Equal(t, uint64(1), ptr.Stat.NotFoundCnt)
+	assert.Equal(t, uint64(1), ptr.Stat.ErrCnt)
+}
+
+func TestPTRMaxHosts(t *testing.T) {
+	srv := newTestDNSServer(t,
+		testRecord{Name: "1.0.0.10.in-addr.arpa", Type: dnsmessage.TypePTR, Value: "web.bench.com"},
+		testRecord{Name: "2.0.0.10.in-addr.arpa", Type: dnsmessage.TypePTR, Value: "mail.bench.com"},
+	)
+	ptr := NewPTR()
+	require.NoError(t, ptr.Init(
+		base.QPS(1000),
+		base.Timeout(200*time.Millisecond),
+		base.Param(ParamResolvers, srv.Addr),
+		base.Param(ParamMaxHosts, "2"),
+	))
+	subdomains, err := ptr.Get(context.Background(), "10.0.0.0/24")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web.bench.com"}, subdomains)
+	assert.Equal(t, uint64(254), ptr.Stat.LimitSkipCnt)
+	assert.Equal(t, uint64(0), ptr.Stat.ReportedCnt)
+
+	// skipped addresses of ipv6 cidrs stay at max uint64 instead of overflowing
+	for i := 0; i < 4; i++ {
+		_, err = ptr.Get(context.Background(), "2001:db8::/64")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, uint64(math.MaxUint64), ptr.Stat.LimitSkipCnt)
+
+	assert.Error(t, NewPTR().Init(base.Param(ParamMaxHosts, "0")))
+}
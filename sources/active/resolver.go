@@ -0,0 +1,76 @@
+package active
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+const (
+	// ParamResolvers is the param of resolvers joined with ',', E.g., '1.1.1.1,8.8.8.8:53'
+	ParamResolvers = "resolvers"
+)
+
+// DefaultResolvers are used if resolvers are not given
+var DefaultResolvers = []string{"1.1.1.1:53", "8.8.8.8:53", "9.9.9.9:53"}
+
+// Resolver sends dns queries to given resolvers in turn
+type Resolver struct {
+	*net.Resolver
+	Addrs []string
+	next  uint32
+}
+
+// NewResolver returns resolver of given addresses, port 53 is used if it's not given
+func NewResolver(addrs ...string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, addr := range addrs {
+		if addr = strings.TrimSpace(addr); len(addr) == 0 {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("resolver should be ip, got %q", addr)
+		}
+		r.Addrs = append(r.Addrs, addr)
+	}
+	if len(r.Addrs) == 0 {
+		return nil, fmt.Errorf("empty resolvers")
+	}
+	r.Resolver = &net.Resolver{PreferGo: true, Dial: r.dial}
+	return r, nil
+}
+
+// resolverOf returns resolver from param of the finder
+func resolverOf(sdf *base.SDFinder) (*Resolver, error) {
+	return NewResolver(strings.Split(sdf.StringParam(ParamResolvers, strings.Join(DefaultResolvers, ",")), ",")...)
+}
+
+// dial ignores the address from system config and connects to the next resolver
+func (r *Resolver) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	addr := r.Addrs[int(atomic.AddUint32(&r.next, 1)-1)%len(r.Addrs)]
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// fqdn appends '.' to the name so that search domains in system config are not applied
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// isNotFound is true if the name does not exist or has no record of the type
+func isNotFound(err error) bool {
+	var derr *net.DNSError
+	return errors.As(err, &derr) && derr.IsNotFound
+}
@@ -0,0 +1,212 @@
+package active
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// TLSCert connects to the ports of host with tls and returns CN and SAN dns names in the peer certificate,
+// the certificate is not verified so that certs which are self-signed or issued by internal CA are included.
+// 'tlscert' serves domain with SNI, while 'tlscert/reverse' serves ip without SNI
+const (
+	NameTLSCert    = "tlscert"
+	NameTLSCertRvs = "tlscert/reverse"
+
+	// ParamPorts is the param of ports to connect joined with ',', E.g., '443,8443,993'
+	ParamPorts = "ports"
+
+	// keys of extra information in output
+	TLSCertInfoIssuer   = "issuer"
+	TLSCertInfoNotAfter = "not_after"
+	TLSCertInfoPort     = "port" // ports serving the name joined with ','
+)
+
+// DefaultTLSPorts are used if ports are not given
+var DefaultTLSPorts = []int{443}
+
+func init() {
+	base.MustRegister(NameTLSCert, NewTLSCert())
+	base.MustRegister(NameTLSCertRvs, NewTLSCertRvs())
+}
+
+type TLSCert struct {
+	base.SDFinder
+	Ports    []int
+	Resolver *Resolver
+}
+
+func NewTLSCert() *TLSCert {
+	return &TLSCert{SDFinder: *base.NewSDFinder()}
+}
+
+func (tc *TLSCert) Init(opts ...base.Option) error {
+	if err := tc.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	var err error
+	if tc.Resolver, err = resolverOf(&tc.SDFinder); err != nil {
+		return err
+	}
+	tc.Ports, err = ParsePorts(tc.StringParam(ParamPorts, ""))
+	return err
+}
+
+// ParsePorts parses ports joined with ',', DefaultTLSPorts is returned if it's empty
+func ParsePorts(portsStr string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]struct{})
+	for _, ps := range strings.Split(portsStr, ",") {
+		if ps = strings.TrimSpace(ps); len(ps) == 0 {
+			continue
+		}
+		port, err := strconv.Atoi(ps)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", ps)
+		}
+		if _, hasseen := seen[port]; !hasseen {
+			seen[port] = struct{}{}
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) == 0 {
+		return DefaultTLSPorts, nil
+	}
+	return ports, nil
+}
+
+// Grab connects to the port of host and returns the leaf certificate without verifying it,
+// SNI is sent if host is not ip. Each connection waits for rate limiter and is retried base on retry policy
+func (tc *TLSCert) Grab(ctx context.Context, host string, port int) (cert *x509.Certificate, err error) {
+	cfg := &tls.Config{InsecureSkipVerify: true}
+	if net.ParseIP(host) == nil {
+		cfg.ServerName = host
+	}
+	d := &tls.Dialer{
+		NetDialer: &net.Dialer{Resolver: tc.Resolver.Resolver},
+		Config:    cfg,
+	}
+	err = tc.RetryDo(ctx, func() error {
+		if err := tc.RLimiter.Wait(ctx); err != nil {
+			return err
+		}
+		dctx, cancel := context.WithTimeout(ctx, tc.Client.Timeout)
+		defer cancel()
+		conn, err := d.DialContext(dctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+		if len(certs) == 0 {
+			return errors.New("no peer certificate")
+		}
+		cert = certs[0]
+		return nil
+	})
+	return cert, err
+}
+
+// CertNames returns CN and SAN dns names in the certificate, wildcard prefix is trimmed
+func CertNames(cert *x509.Certificate) []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		name = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(name), "*."), ".")
+		if _, hasseen := seen[name]; len(name) == 0 || hasseen || net.ParseIP(name) != nil {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
+
+// issuerOf returns CN of the issuer, or the whole distinguished name if CN is empty
+func issuerOf(cert *x509.Certificate) string {
+	if len(cert.Issuer.CommonName) > 0 {
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
+
+// GetWithInfo grabs certificate from each port in turn, ports which are not reachable or not serving tls
+// are skipped, and the error is returned only if all the ports fail. Issuer and not after of the name
+// are taken from the certificate on the first port serving it
+func (tc *TLSCert) GetWithInfo(ctx context.Context, host string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		tc.RecordStat(subdomains, err)
+	}()
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	info = make(base.ExInfo)
+	var failed int
+	for _, port := range tc.Ports {
+		cert, err := tc.Grab(ctx, host, port)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err != nil {
+			if failed++; failed == len(tc.Ports) {
+				return nil, nil, err
+			}
+			continue
+		}
+		for _, name := range CertNames(cert) {
+			if ports := info.Get(name, TLSCertInfoPort); len(ports) > 0 {
+				info.Set(name, TLSCertInfoPort, ports+","+strconv.Itoa(port))
+				continue
+			}
+			subdomains = append(subdomains, name)
+			info.Set(name, TLSCertInfoIssuer, issuerOf(cert))
+			info.Set(name, TLSCertInfoNotAfter, cert.NotAfter.UTC().Format(time.RFC3339))
+			info.Set(name, TLSCertInfoPort, strconv.Itoa(port))
+		}
+	}
+	return subdomains, info, nil
+}
+
+func (tc *TLSCert) Get(ctx context.Context, host string) ([]string, error) {
+	subdomains, _, err := tc.GetWithInfo(ctx, host)
+	return subdomains, err
+}
+
+func (tc *TLSCert) RelatedMethod() string {
+	return base.FromActive
+}
+
+func (tc *TLSCert) Name() string {
+	return NameTLSCert
+}
+
+type TLSCertRvs struct {
+	TLSCert
+}
+
+func NewTLSCertRvs() *TLSCertRvs {
+	return &TLSCertRvs{TLSCert{SDFinder: *base.NewSDFinder()}}
+}
+
+func (tcr *TLSCertRvs) Init(opts ...base.Option) error {
+	return tcr.TLSCert.Init(opts...)
+}
+
+func (tcr *TLSCertRvs) ServeType() base.InputType {
+	return base.InputIP
+}
+
+func (tcr *TLSCertRvs) RelatedType() string {
+	return base.RLPRvsDNS
+}
+
+func (tcr *TLSCertRvs) Name() string {
+	return NameTLSCertRvs
+}
@@ -0,0 +1,135 @@
+package active
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+var testNotAfter = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestCert(t *testing.T, cn string, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		Issuer:       pkix.Name{CommonName: "Bench Internal CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     testNotAfter,
+		DNSNames:     dnsNames,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	caTmpl := *tmpl
+	caTmpl.Subject = pkix.Name{CommonName: "Bench Internal CA"}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, &caTmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func testPort(t *testing.T, srv *httptest.Server) string {
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	return port
+}
+
+func TestParsePorts(t *testing.T) {
+	ports, err := ParsePorts("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTLSPorts, ports)
+	ports, err = ParsePorts("443, 8443,993,443")
+	require.NoError(t, err)
+	assert.Equal(t, []int{443, 8443, 993}, ports)
+	_, err = ParsePorts("443,https")
+	assert.Error(t, err)
+	_, err = ParsePorts("65536")
+	assert.Error(t, err)
+}
+
+func TestTLSCert(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		sni []string
+	)
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{newTestCert(t, "tls.bench.com", "tls.bench.com", "*.api.bench.com", "other.com")},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			sni = append(sni, hello.ServerName)
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	// the port which is not listening
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	dnsSrv := newTestDNSServer(t, testRecord{Name: "tls.bench.com", Type: dnsmessage.TypeA, Value: "127.0.0.1"})
+	tc := NewTLSCert()
+	require.NoError(t, tc.Init(
+		base.QPS(1000),
+		base.Timeout(time.Second),
+		base.Param(ParamResolvers, dnsSrv.Addr),
+		base.Param(ParamPorts, testPort(t, closed)+","+testPort(t, srv)),
+	))
+	assert.Equal(t, base.InputDomain, tc.ServeType())
+	subdomains, info, err := tc.GetWithInfo(context.Background(), "tls.bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tls.bench.com", "api.bench.com", "other.com"}, subdomains)
+	assert.Equal(t, "Bench Internal CA", info.Get("api.bench.com", TLSCertInfoIssuer))
+	assert.Equal(t, "2030-01-02T03:04:05Z", info.Get("api.bench.com", TLSCertInfoNotAfter))
+	assert.Equal(t, testPort(t, srv), info.Get("api.bench.com", TLSCertInfoPort))
+	mu.Lock()
+	assert.Contains(t, sni, "tls.bench.com")
+	mu.Unlock()
+
+	// all ports fail
+	tc.Ports = []int{8443}
+	_, err = tc.Get(context.Background(), "notexist.bench.com")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), tc.Stat.FoundCnt)
+	assert.Equal(t, uint64(1), tc.Stat.ErrCnt)
+}
+
+func TestTLSCertRvs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	srv2 := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv2.Close()
+
+	tcr := NewTLSCertRvs()
+	require.NoError(t, tcr.Init(
+		base.QPS(1000),
+		base.Timeout(time.Second),
+		base.Param(ParamPorts, testPort(t, srv)+","+testPort(t, srv2)),
+	))
+	assert.Equal(t, base.InputIP, tcr.ServeType())
+	assert.Equal(t, base.RLPRvsDNS, tcr.RelatedType())
+	assert.Equal(t, "active/tlscert/reverse", tcr.RelatedMethod()+"/"+tcr.Name())
+	subdomains, info, err := tcr.GetWithInfo(context.Background(), "127.0.0.1")
+	require.NoError(t, err)
+	// certificate of httptest server
+	assert.Equal(t, []string{"example.com"}, subdomains)
+	assert.Equal(t, testPort(t, srv)+","+testPort(t, srv2), info.Get("example.com", TLSCertInfoPort))
+	assert.Equal(t, "O=Acme Co", info.Get("example.com", TLSCertInfoIssuer))
+}
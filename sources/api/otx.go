@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://otx.alienvault.com/api/v1/indicators/domain/<domain>/passive_dns
+// rate limit: 10000 req / hour with api key, lower without api key
+/*{
+    "passive_dns": [
+        {
+            "address": "142.250.66.78",
+            "first": "2021-06-13T06:47:07",
+            "last": "2022-05-30T11:55:07",
+            "hostname": "mail.google.com",
+            "record_type": "A",
+            "asset_type": "hostname",
+            "asn": "AS15169 google"
+        }
+    ],
+    "count": 1
+}*/
+const NameOTX = "otx"
+
+func init() {
+	base.MustRegister(NameOTX, NewOTX())
+}
+
+// keys of extra information in output
+const (
+	OTXInfoIP        = "ip"
+	OTXInfoCNAME     = "cname"
+	OTXInfoFirstSeen = "first_seen"
+	OTXInfoLastSeen  = "last_seen"
+)
+
+type OTX struct{ base.SDFinder }
+type OTXRsp struct {
+	PassiveDNS []OTXRecord `json:"passive_dns"`
+	Count      int         `json:"count"`
+}
+type OTXRecord struct {
+	Address    string `json:"address"`
+	First      string `json:"first"`
+	Last       string `json:"last"`
+	Hostname   string `json:"hostname"`
+	RecordType string `json:"record_type"`
+}
+
+func NewOTX() *OTX {
+	return &OTX{*base.NewSDFinder()}
+}
+
+func (o *OTX) Init(opts ...base.Option) error {
+	o.URLbuilder = func(domain string) string {
+		return "https://otx.alienvault.com/api/v1/indicators/domain/" + domain + "/passive_dns"
+	}
+	o.Parse = func(content []byte) ([]string, error) {
+		subdomains, _, err := parseOTX(content)
+		return subdomains, err
+	}
+	// api key is optional, which raises the rate limit
+	opts = append([]base.Option{base.KeyPlacement(base.KeyInHeader, "X-OTX-API-KEY", "")}, opts...)
+	return o.SDFinder.Init(opts...)
+}
+
+// parseOTX extracts hostnames from passive dns records, the resolved addresses of the same hostname
+// are joined with ',', and the earliest first seen and the latest last seen are kept
+func parseOTX(content []byte) ([]string, base.ExInfo, error) {
+	var otxrsp OTXRsp
+	if err := json.Unmarshal(content, &otxrsp); err != nil {
+		return nil, nil, err
+	}
+	var subdomains []string
+	info := make(base.ExInfo)
+	addrs := make(map[string]map[string][]string) // hostname -> info key -> addresses
+	for _, record := range otxrsp.PassiveDNS {
+		hostname := strings.ToLower(strings.TrimSuffix(record.Hostname, "."))
+		if len(hostname) == 0 {
+			continue
+		}
+		if _, exist := addrs[hostname]; !exist {
+			addrs[hostname] = make(map[string][]string)
+			subdomains = append(subdomains, hostname)
+		}
+		key := OTXInfoIP
+		if record.RecordType == "CNAME" {
+			key = OTXInfoCNAME
+		} else if record.RecordType != "A" && record.RecordType != "AAAA" {
+			key = ""
+		}
+		if len(key) > 0 && len(record.Address) > 0 {
+			addrs[hostname][key] = append(addrs[hostname][key], record.Address)
+		}
+		// timestamps are in the same format, which could be compared as strings
+		if first := info.Get(hostname, OTXInfoFirstSeen); len(record.First) > 0 && (len(first) == 0 || record.First < first) {
+			info.Set(hostname, OTXInfoFirstSeen, record.First)
+		}
+		if last := info.Get(hostname, OTXInfoLastSeen); record.Last > last {
+			info.Set(hostname, OTXInfoLastSeen, record.Last)
+		}
+	}
+	for hostname, keyAddrs := range addrs {
+		for key, vals := range keyAddrs {
+			info.Set(hostname, key, strings.Join(uniqSorted(vals), ","))
+		}
+	}
+	return subdomains, info, nil
+}
+
+func uniqSorted(vals []string) []string {
+	sort.Strings(vals)
+	var result []string
+	for i, val := range vals {
+		if i == 0 || val != vals[i-1] {
+			result = append(result, val)
+		}
+	}
+	return result
+}
+
+func (o *OTX) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		o.RecordStat(subdomains, err)
+	}()
+	content, err := o.Fetch(ctx, o.URLbuilder(domain))
+	if err != nil {
+		return nil, nil, err
+	}
+	subdomains, info, err = parseOTX(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return subdomains, info, nil
+}
+
+func (o *OTX) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := o.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
+
+func (OTX) Name() string {
+	return NameOTX
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func NewMockOTXServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if key := req.Header.Get("X-OTX-API-KEY"); len(key) > 0 && key != "otxkey" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"passive_dns": [
+			{"address": "1.1.1.2", "first": "2021-06-13T06:47:07", "last": "2022-05-30T11:55:07",
+				"hostname": "mail.abc.com", "record_type": "A", "asset_type": "hostname"},
+			{"address": "1.1.1.1", "first": "2020-01-02T00:00:00", "last": "2021-01-02T00:00:00",
+				"hostname": "Mail.abc.com", "record_type": "A", "asset_type": "hostname"},
+			{"address": "2001:db8::1", "first": "2021-01-02T00:00:00", "last": "2021-01-03T00:00:00",
+				"hostname": "mail.abc.com", "record_type": "AAAA", "asset_type": "hostname"},
+			{"address": "abc.cdn.net", "first": "2022-01-01T00:00:00", "last": "2022-02-01T00:00:00",
+				"hostname": "www.abc.com", "record_type": "CNAME", "asset_type": "hostname"},
+			{"address": "NXDOMAIN", "first": "2022-01-01T00:00:00", "last": "2022-01-01T00:00:00",
+				"hostname": "old.abc.com", "record_type": "NXDOMAIN", "asset_type": "hostname"}
+		], "count": 5}`))
+	}))
+}
+
+func TestOTX(t *testing.T) {
+	testSrv := NewMockOTXServer()
+	testSrv.Start()
+	defer testSrv.Close()
+
+	testURLBuilder := func(domain string) string {
+		return fmt.Sprintf("%s/api/v1/indicators/domain/%s/passive_dns", testSrv.URL, domain)
+	}
+	otx := NewOTX()
+	require.NoError(t, otx.Init(base.UrlBuilder(testURLBuilder), base.QPS(100)))
+	subdomains, info, err := otx.GetWithInfo(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	exp := []string{
+		"mail.abc.com",
+		"old.abc.com",
+		"www.abc.com",
+	}
+	assert.Equal(t, exp, subdomains)
+	assert.Equal(t, map[string]string{
+		OTXInfoIP:        "1.1.1.1,1.1.1.2,2001:db8::1",
+		OTXInfoFirstSeen: "2020-01-02T00:00:00",
+		OTXInfoLastSeen:  "2022-05-30T11:55:07",
+	}, info["mail.abc.com"])
+	assert.Equal(t, "abc.cdn.net", info.Get("www.abc.com", OTXInfoCNAME))
+	assert.Empty(t, info.Get("old.abc.com", OTXInfoIP))
+	assert.Equal(t, otx.Stat.DomainsCnt, uint64(1))
+	assert.Equal(t, otx.Stat.SuccessCnt, uint64(1))
+	assert.Equal(t, otx.Stat.RelatedDomainCnt, uint64(len(exp)))
+
+	// with api key
+	otx = NewOTX()
+	require.NoError(t, otx.Init(base.UrlBuilder(testURLBuilder), base.APIKeys("otxkey")))
+	subdomains, err = otx.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Len(t, subdomains, 3)
+
+	otx = NewOTX()
+	require.NoError(t, otx.Init(base.UrlBuilder(testURLBuilder), base.APIKeys("wrong")))
+	_, err = otx.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://api.securitytrails.com/v1/domain/<domain>/subdomains?children_only=false&include_inactive=true
+// api key is required in 'APIKEY' header, the labels are returned without the input domain
+/*{
+    "endpoint": "/v1/domain/bench.com/subdomains",
+    "meta": {"limit_reached": true},
+    "subdomain_count": 3,
+    "subdomains": ["www", "mail", "dev.api"]
+}*/
+const (
+	NameSecurityTrails = "securitytrails"
+
+	// SecurityTrailsKeyEnv is the environment variable of api key used if keys are not given in config
+	SecurityTrailsKeyEnv = "SECURITYTRAILS_API_KEY"
+)
+
+func init() {
+	base.MustRegister(NameSecurityTrails, NewSecurityTrails())
+}
+
+type SecurityTrails struct{ base.SDFinder }
+type SecurityTrailsRsp struct {
+	SubdomainCount int      `json:"subdomain_count"`
+	Subdomains     []string `json:"subdomains"`
+}
+
+func NewSecurityTrails() *SecurityTrails {
+	return &SecurityTrails{*base.NewSDFinder()}
+}
+
+func (st *SecurityTrails) Init(opts ...base.Option) error {
+	st.URLbuilder = func(domain string) string {
+		return "https://api.securitytrails.com/v1/domain/" + domain + "/subdomains?children_only=false&include_inactive=true"
+	}
+	// default placement of api key, which could be overwritten by given options
+	opts = append([]base.Option{base.KeyPlacement(base.KeyInHeader, "APIKEY", "")}, opts...)
+	if err := st.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	if st.Keys.Len() == 0 {
+		// fallback to environment variable if keys are not given
+		if key := os.Getenv(SecurityTrailsKeyEnv); len(key) > 0 {
+			return base.APIKeys(key)(&st.SDFinder)
+		}
+		return fmt.Errorf("api key is required for %s, set it in config or %s", NameSecurityTrails, SecurityTrailsKeyEnv)
+	}
+	return nil
+}
+
+func (SecurityTrails) Name() string {
+	return NameSecurityTrails
+}
+
+// Get joins labels with the input domain, and records 'subdomain_count' reported by source
+// to find out domains whose subdomains are truncated
+func (st *SecurityTrails) Get(ctx context.Context, domain string) (subdomains []string, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		st.RecordStat(subdomains, err)
+	}()
+	content, err := st.Fetch(ctx, st.URLbuilder(domain))
+	if err != nil {
+		return nil, err
+	}
+	var strsp SecurityTrailsRsp
+	if err := json.Unmarshal(content, &strsp); err != nil {
+		return nil, err
+	}
+	for _, label := range strsp.Subdomains {
+		if len(label) > 0 {
+			subdomains = append(subdomains, label+"."+domain)
+		}
+	}
+	subdomains = base.Uniq(subdomains)
+	st.RecordReported(strsp.SubdomainCount, len(subdomains))
+	return subdomains, nil
+}
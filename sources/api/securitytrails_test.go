@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func NewMockSecurityTrailsServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("APIKEY") != "stkey" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/domain/abc.com/subdomains":
+			w.Write([]byte(`{"endpoint": "/v1/domain/abc.com/subdomains", "meta": {"limit_reached": true},` +
+				`"subdomain_count": 5, "subdomains": ["www", "mail", "dev.api"]}`))
+		case "/v1/domain/cde.com/subdomains":
+			w.Write([]byte(`{"endpoint": "/v1/domain/cde.com/subdomains", "meta": {"limit_reached": false},` +
+				`"subdomain_count": 1, "subdomains": ["www"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestSecurityTrails(t *testing.T) {
+	testSrv := NewMockSecurityTrailsServer()
+	testSrv.Start()
+	defer testSrv.Close()
+
+	testURLBuilder := func(domain string) string {
+		return fmt.Sprintf("%s/v1/domain/%s/subdomains?children_only=false&include_inactive=true", testSrv.URL, domain)
+	}
+	st := NewSecurityTrails()
+	require.NoError(t, st.Init(base.UrlBuilder(testURLBuilder), base.QPS(100), base.APIKeys("stkey")))
+	subdomains, err := st.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	exp := []string{
+		"dev.api.abc.com",
+		"mail.abc.com",
+		"www.abc.com",
+	}
+	assert.Equal(t, exp, subdomains)
+	subdomains, err = st.Get(context.Background(), "cde.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.cde.com"}, subdomains)
+	assert.Equal(t, st.Stat.DomainsCnt, uint64(2))
+	assert.Equal(t, st.Stat.SuccessCnt, uint64(2))
+	assert.Equal(t, st.Stat.RelatedDomainCnt, uint64(4))
+	assert.Equal(t, st.Stat.ReportedCnt, uint64(6))
+	assert.Equal(t, st.Stat.IncompleteCnt, uint64(1))
+
+	// wrong key
+	st = NewSecurityTrails()
+	require.NoError(t, st.Init(base.UrlBuilder(testURLBuilder), base.APIKeys("wrong")))
+	_, err = st.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Equal(t, st.Stat.ErrCnt, uint64(1))
+	assert.Equal(t, st.Stat.ReportedCnt, uint64(0))
+}
+
+func TestSecurityTrailsAPIKey(t *testing.T) {
+	t.Setenv(SecurityTrailsKeyEnv, "")
+	assert.Error(t, NewSecurityTrails().Init())
+
+	t.Setenv(SecurityTrailsKeyEnv, "stkey")
+	st := NewSecurityTrails()
+	require.NoError(t, st.Init())
+	assert.Equal(t, 1, st.Keys.Len())
+	assert.Equal(t, "APIKEY", st.Keys.Name)
+}
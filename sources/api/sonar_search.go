@@ -3,13 +3,23 @@ package api
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
 	pb "github.com/cgboal/sonarsearch/proto"
 	"golang.org/x/net/publicsuffix"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 
 	"github.com/shlin168/sdfinder/sources/base"
 )
@@ -21,6 +31,32 @@ const NameSonarSearch = "sonarsearch"
 const NameSonarSearchSbs = "sonarsearch/subdomains"
 const NameSonarSearchRvs = "sonarsearch/reverse"
 
+const (
+	// DefaultSonarSearchKeepalive respects the minimum ping interval of grpc server by default
+	DefaultSonarSearchKeepalive  = 5 * time.Minute
+	DefaultSonarSearchMaxBackoff = 30 * time.Second
+
+	// ParamAddress is the param of server address, E.g., 'crobat.internal:443'. It's required since the
+	// public endpoint 'crobat-rpc.omnisint.io:443' has been retired
+	ParamAddress = "address"
+	// ParamPlaintext is the param to connect without tls, E.g., 'true'
+	ParamPlaintext = "plaintext"
+	// ParamCA is the param of pem file of CA certificates to verify server, system CA is used if not given
+	ParamCA = "ca"
+	// ParamCert and ParamKey are the params of pem files of client certificate and key, both should be given
+	ParamCert = "cert"
+	ParamKey  = "key"
+	// ParamKeepalive is the param of interval to ping server while queries are in-flight
+	ParamKeepalive = "keepalive"
+	// ParamMaxBackoff is the param of max interval to reconnect after the connection is broken
+	ParamMaxBackoff = "max_backoff"
+	// ParamStreamTimeout is the param of max duration of each query including the streamed response,
+	// the stream is not limited by default since it takes long for domains with lots of subdomains
+	ParamStreamTimeout = "stream_timeout"
+)
+
+var errNoResponse = errors.New("no response from server")
+
 func init() {
 	base.MustRegister(NameSonarSearchSbs, NewSonarSearchSbs())
 	base.MustRegister(NameSonarSearchRvs, NewSonarSearchRvs())
@@ -28,8 +64,9 @@ func init() {
 
 type SonarSearch struct {
 	base.SDFinder
-	conn *grpc.ClientConn
-	cli  pb.CrobatClient
+	StreamTimeout time.Duration
+	conn          *grpc.ClientConn
+	cli           pb.CrobatClient
 }
 
 type SonarSearchSbs struct {
@@ -42,60 +79,225 @@ func NewSonarSearch() *SonarSearch {
 	return &SonarSearch{SDFinder: *base.NewSDFinder()}
 }
 
+// Init connects to the server and fails if the connection is not ready within timeout. The connection
+// is reconnected with backoff once it's broken, and queries wait for the first response within timeout.
+// The address is given by param, or by url builder if it's set by option
 func (ss *SonarSearch) Init(opts ...base.Option) error {
-	config := &tls.Config{}
-	ss.URLbuilder = func(domain string) string {
-		return "crobat-rpc.omnisint.io:443"
+	if err := ss.SDFinder.Init(opts...); err != nil {
+		return err
 	}
-	var err error
-	ss.conn, err = grpc.Dial(ss.URLbuilder(""), grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	if ss.URLbuilder == nil {
+		addr := ss.StringParam(ParamAddress, "")
+		if len(addr) == 0 {
+			return fmt.Errorf("param %q is required for %s", ParamAddress, NameSonarSearch)
+		}
+		ss.URLbuilder = func(domain string) string {
+			return addr
+		}
+	}
+	addr := ss.URLbuilder("")
+	creds, err := ss.credentials()
+	if err != nil {
+		return err
+	}
+	keepaliveTime, err := ss.DurationParam(ParamKeepalive, DefaultSonarSearchKeepalive)
 	if err != nil {
 		return err
 	}
+	maxBackoff, err := ss.DurationParam(ParamMaxBackoff, DefaultSonarSearchMaxBackoff)
+	if err != nil {
+		return err
+	}
+	if ss.StreamTimeout, err = ss.DurationParam(ParamStreamTimeout, 0); err != nil {
+		return err
+	}
+	bo := backoff.DefaultConfig
+	bo.MaxDelay = maxBackoff
+	ss.conn, err = grpc.NewClient(addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: keepaliveTime, Timeout: ss.Client.Timeout}),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bo, MinConnectTimeout: ss.Client.Timeout}),
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(true)),
+	)
+	if err != nil {
+		return err
+	}
+	if err := ss.waitReady(addr); err != nil {
+		ss.conn.Close()
+		ss.conn = nil
+		return err
+	}
 	ss.cli = pb.NewCrobatClient(ss.conn)
-	return ss.SDFinder.Init(opts...)
+	return nil
 }
 
-func (ss *SonarSearch) Get(ctx context.Context, domain string) (subdomains []string, err error) {
-	sbChan, err := ss.GetChan(ctx, domain)
+// credentials returns plaintext or tls credentials from params
+func (ss *SonarSearch) credentials() (credentials.TransportCredentials, error) {
+	plaintext, err := ss.BoolParam(ParamPlaintext, false)
 	if err != nil {
 		return nil, err
 	}
-	uniDomainMap := make(map[string]struct{})
-	for sb := range sbChan {
-		sblower := strings.ToLower(sb)
-		uniDomainMap[sblower] = struct{}{}
+	if plaintext {
+		return insecure.NewCredentials(), nil
 	}
-	for sb := range uniDomainMap {
-		subdomains = append(subdomains, sb)
+	config := &tls.Config{}
+	if ca := ss.StringParam(ParamCA, ""); len(ca) > 0 {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("read ca error: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in ca %q", ca)
+		}
 	}
-	return subdomains, nil
+	cert, key := ss.StringParam(ParamCert, ""), ss.StringParam(ParamKey, "")
+	if len(cert) > 0 || len(key) > 0 {
+		if len(cert) == 0 || len(key) == 0 {
+			return nil, fmt.Errorf("both param %q and %q should be given for client certificate", ParamCert, ParamKey)
+		}
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error: %v", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return credentials.NewTLS(config), nil
 }
 
-func (ss *SonarSearch) GetChan(ctx context.Context, domain string) (chan string, error) {
-	if err := ss.RLimiter.Wait(ctx); err != nil {
+// waitReady connects to the server and waits until the connection is ready as health check
+func (ss *SonarSearch) waitReady(addr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ss.Client.Timeout)
+	defer cancel()
+	ss.conn.Connect()
+	for {
+		state := ss.conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !ss.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection to %s is not ready in %v, last state: %s", addr, ss.Client.Timeout, state)
+		}
+	}
+}
+
+func (ss *SonarSearch) Get(ctx context.Context, domain string) (subdomains []string, err error) {
+	sbChan, errChan, err := ss.GetChan(ctx, domain)
+	if err != nil {
 		return nil, err
 	}
+	return uniqFromChan(sbChan, errChan)
+}
+
+// GetChan streams subdomains to the channel, which is closed once the stream ends. The error of stream is
+// sent to error channel after that, nil if the stream ends normally. The query fails if the first response
+// is not received within timeout, so that it does not wait for reconnection forever if the server goes down,
+// while the rest of stream is limited only by stream timeout.
+//
+// The error channel is returned since the channel alone could not tell broken stream from the end of it,
+// callers of the previous signature 'GetChan(ctx, domain) (chan string, error)' should read the error
+// channel once the result channel is closed
+func (ss *SonarSearch) GetChan(ctx context.Context, domain string) (chan string, chan error, error) {
+	if err := ss.RLimiter.Wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel, received := ss.queryCtx(ctx)
 	req := &pb.QueryRequest{Query: domain}
 	res, err := ss.cli.GetSubdomains(ctx, req)
 	if err != nil {
-		return nil, err
+		cancel()
+		return nil, nil, causeOf(ctx, err)
+	}
+	resultChan, errChan := recvDomains(ctx, cancel, received, res)
+	return resultChan, errChan, nil
+}
+
+// queryCtx returns context of the query, which is canceled with errNoResponse if received is not called
+// within timeout of the source, and limited by stream timeout if it's given
+func (ss *SonarSearch) queryCtx(ctx context.Context) (context.Context, context.CancelFunc, func()) {
+	streamCancel := context.CancelFunc(func() {})
+	if ss.StreamTimeout > 0 {
+		ctx, streamCancel = context.WithTimeout(ctx, ss.StreamTimeout)
+	}
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	cancel := func() {
+		cancelCause(nil)
+		streamCancel()
+	}
+	if ss.Client.Timeout <= 0 {
+		return ctx, cancel, func() {}
 	}
-	resultChan := make(chan string)
+	timer := time.AfterFunc(ss.Client.Timeout, func() {
+		cancelCause(fmt.Errorf("%w in %v", errNoResponse, ss.Client.Timeout))
+	})
+	return ctx, cancel, func() { timer.Stop() }
+}
+
+// causeOf returns errNoResponse instead of the canceled error if the query is canceled since
+// the first response is not received in time
+func causeOf(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errNoResponse) {
+		return cause
+	}
+	return err
+}
+
+// domainStream is the stream of GetSubdomains and ReverseDNS
+type domainStream interface {
+	Recv() (*pb.Domain, error)
+}
+
+// recvDomains sends domains received from stream to result channel until the stream ends, then sends
+// the error to error channel, io.EOF is not an error. received is called once anything is received from
+// stream, and cancel is called once the stream ends
+func recvDomains(ctx context.Context, cancel context.CancelFunc, received func(), res domainStream) (chan string, chan error) {
+	resultChan, errChan := make(chan string), make(chan error, 1)
 	go func() {
+		defer cancel()
+		defer close(resultChan)
 		for {
 			domain, err := res.Recv()
+			received()
 			if err != nil {
-				close(resultChan)
+				if err == io.EOF {
+					err = nil
+				}
+				errChan <- causeOf(ctx, err)
+				return
+			}
+			select {
+			case resultChan <- domain.Domain:
+			case <-ctx.Done():
+				errChan <- causeOf(ctx, ctx.Err())
 				return
 			}
-			resultChan <- domain.Domain
 		}
 	}()
-	return resultChan, nil
+	return resultChan, errChan
 }
 
+// uniqFromChan collects lowercase domains from channel without duplicates, nothing is returned
+// if the stream fails, since subdomains might be truncated
+func uniqFromChan(sbChan chan string, errChan chan error) (subdomains []string, err error) {
+	uniDomainMap := make(map[string]struct{})
+	for sb := range sbChan {
+		sblower := strings.ToLower(sb)
+		uniDomainMap[sblower] = struct{}{}
+	}
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	for sb := range uniDomainMap {
+		subdomains = append(subdomains, sb)
+	}
+	return subdomains, nil
+}
+
+// Close closes the connection, which is invoked by executor once all the queries finish
 func (ss *SonarSearch) Close() error {
+	if ss.conn == nil {
+		return nil
+	}
 	return ss.conn.Close()
 }
 
@@ -113,6 +315,7 @@ func (ss SonarSearchSbs) Name() string {
 
 func (sbs *SonarSearchSbs) Get(ctx context.Context, domain string) (subdomains []string, err error) {
 	defer func() {
+		err = base.CtxErr(ctx, err)
 		sbs.RecordStat(subdomains, err)
 	}()
 	publicSuffix, _ := publicsuffix.PublicSuffix(domain)
@@ -165,42 +368,29 @@ func (ss SonarSearchRvs) Name() string {
 
 func (ss *SonarSearchRvs) Get(ctx context.Context, ip string) (subdomains []string, err error) {
 	defer func() {
+		err = base.CtxErr(ctx, err)
 		ss.RecordStat(subdomains, err)
 	}()
-	sbChan, err := ss.GetChan(ctx, ip)
+	sbChan, errChan, err := ss.GetChan(ctx, ip)
 	if err != nil {
 		return nil, err
 	}
-	uniDomainMap := make(map[string]struct{})
-	for sb := range sbChan {
-		sblower := strings.ToLower(sb)
-		uniDomainMap[sblower] = struct{}{}
-	}
-	for sb := range uniDomainMap {
-		subdomains = append(subdomains, sb)
-	}
-	return subdomains, nil
+	return uniqFromChan(sbChan, errChan)
 }
 
-func (ss *SonarSearchRvs) GetChan(ctx context.Context, ip string) (chan string, error) {
+// GetChan streams names of the ip to the channel as SonarSearch.GetChan, of which signature
+// changes in the same way
+func (ss *SonarSearchRvs) GetChan(ctx context.Context, ip string) (chan string, chan error, error) {
 	if err := ss.RLimiter.Wait(ctx); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	ctx, cancel, received := ss.queryCtx(ctx)
 	req := &pb.QueryRequest{Query: ip}
 	res, err := ss.cli.ReverseDNS(ctx, req)
 	if err != nil {
-		return nil, err
+		cancel()
+		return nil, nil, causeOf(ctx, err)
 	}
-	resultChan := make(chan string)
-	go func() {
-		for {
-			domain, err := res.Recv()
-			if err != nil {
-				close(resultChan)
-				return
-			}
-			resultChan <- domain.Domain
-		}
-	}()
-	return resultChan, nil
+	resultChan, errChan := recvDomains(ctx, cancel, received, res)
+	return resultChan, errChan, nil
 }
@@ -2,17 +2,33 @@ package api
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"log"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	pb "github.com/cgboal/sonarsearch/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
 )
 
 const bufSize = 1024 * 1024
@@ -41,6 +57,26 @@ type CrobatServer struct {
 
 func (cs *CrobatServer) GetSubdomains(q *pb.QueryRequest, stream pb.Crobat_GetSubdomainsServer) error {
 	domain := q.Query
+	switch domain {
+	case "broken.com":
+		// stream breaks after the first reply
+		if err := stream.Send(&pb.Domain{Domain: "abc.broken.com"}); err != nil {
+			return err
+		}
+		return status.Error(codes.Internal, "broken")
+	case "hang.com":
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	case "slow.com":
+		// the rest of stream takes longer than timeout after the first reply
+		for _, subdomain := range []string{"abc.slow.com", "test.slow.com"} {
+			if err := stream.Send(&pb.Domain{Domain: subdomain}); err != nil {
+				return err
+			}
+			time.Sleep(400 * time.Millisecond)
+		}
+		return nil
+	}
 	for _, fstLvl := range []string{"abc", "test", "abc"} {
 		subdomain := fstLvl + "." + domain
 		reply := &pb.Domain{
@@ -131,3 +167,208 @@ func TestSonarSearchRvs(t *testing.T) {
 
 	srv.Stop()
 }
+
+// writeTestPKI writes CA, server certificate for 127.0.0.1 and client certificate to dir
+func writeTestPKI(t *testing.T, dir string) (caPEM []byte, server, client tls.Certificate) {
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		return key
+	}
+	writePEM := func(name, typ string, der []byte) []byte {
+		buf := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), buf, 0600))
+		return buf
+	}
+	caKey := newKey()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caPEM = writePEM("ca.pem", "CERTIFICATE", caDER)
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) tls.Certificate {
+		key := newKey()
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caTmpl, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		cert, err := tls.X509KeyPair(writePEM(name+".pem", "CERTIFICATE", der), writePEM(name+"-key.pem", "EC PRIVATE KEY", keyDER))
+		require.NoError(t, err)
+		return cert
+	}
+	return caPEM, issue("server", 2, x509.ExtKeyUsageServerAuth), issue("client", 3, x509.ExtKeyUsageClientAuth)
+}
+
+func startTCPGrpcServer(t *testing.T, opts ...grpc.ServerOption) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(opts...)
+	pb.RegisterCrobatServer(s, &CrobatServer{})
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func TestSonarSearchInit(t *testing.T) {
+	addr := startTCPGrpcServer(t)
+	ss := NewSonarSearchRvs()
+	require.NoError(t, ss.Init(
+		base.Timeout(time.Second),
+		base.Param(ParamAddress, addr),
+		base.Param(ParamPlaintext, "true"),
+		base.Param(ParamKeepalive, "10s"),
+	))
+	assert.Equal(t, addr, ss.URLbuilder(""))
+	sds, err := ss.Get(context.Background(), "1.2.1.2")
+	require.NoError(t, err)
+	sort.Strings(sds)
+	assert.Equal(t, []string{"abc.trendmicro.com", "test.trendmicro.com"}, sds)
+	assert.NoError(t, ss.Close())
+
+	// address given by url builder is not overridden
+	ss = NewSonarSearchRvs()
+	require.NoError(t, ss.Init(
+		base.Timeout(time.Second),
+		base.UrlBuilder(func(string) string { return addr }),
+		base.Param(ParamPlaintext, "true"),
+	))
+	assert.Equal(t, addr, ss.URLbuilder(""))
+	assert.NoError(t, ss.Close())
+
+	// health check fails if server is not reachable
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := l.Addr().String()
+	l.Close()
+	ss = NewSonarSearchRvs()
+	assert.Error(t, ss.Init(
+		base.Timeout(300*time.Millisecond),
+		base.Param(ParamAddress, closedAddr),
+		base.Param(ParamPlaintext, "true"),
+	))
+	assert.NoError(t, ss.Close())
+
+	// invalid params
+	assert.Error(t, NewSonarSearchRvs().Init())
+	assert.Error(t, NewSonarSearchRvs().Init(base.Param(ParamAddress, addr), base.Param(ParamPlaintext, "yes?")))
+	assert.Error(t, NewSonarSearchRvs().Init(base.Param(ParamAddress, addr), base.Param(ParamKeepalive, "0s")))
+	assert.Error(t, NewSonarSearchRvs().Init(base.Param(ParamAddress, addr), base.Param(ParamStreamTimeout, "0s")))
+}
+
+func TestSonarSearchStreamError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	pb.RegisterCrobatServer(srv, &CrobatServer{})
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	ss := NewSonarSearchSbs()
+	require.NoError(t, ss.Init(
+		base.QPS(100),
+		base.Timeout(300*time.Millisecond),
+		base.Param(ParamAddress, l.Addr().String()),
+		base.Param(ParamPlaintext, "true"),
+	))
+	defer ss.Close()
+	ctx := context.Background()
+	// truncated stream is not success
+	sds, err := ss.Get(ctx, "broken.com")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Empty(t, sds)
+	// the first response is limited by timeout
+	start := time.Now()
+	_, err = ss.Get(ctx, "hang.com")
+	assert.ErrorIs(t, err, errNoResponse)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, uint64(2), ss.Stat.ErrCnt)
+	// the rest of stream is not limited by timeout
+	sds, err = ss.Get(ctx, "slow.com")
+	require.NoError(t, err)
+	sort.Strings(sds)
+	assert.Equal(t, []string{"abc.slow.com", "test.slow.com"}, sds)
+
+	// queries do not wait forever if server goes down
+	srv.Stop()
+	start = time.Now()
+	_, err = ss.Get(ctx, "test.org")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestSonarSearchStreamTimeout(t *testing.T) {
+	addr := startTCPGrpcServer(t)
+	ss := NewSonarSearchSbs()
+	require.NoError(t, ss.Init(
+		base.QPS(100),
+		base.Timeout(300*time.Millisecond),
+		base.Param(ParamAddress, addr),
+		base.Param(ParamPlaintext, "true"),
+		base.Param(ParamStreamTimeout, "600ms"),
+	))
+	defer ss.Close()
+	assert.Equal(t, 600*time.Millisecond, ss.StreamTimeout)
+	// the whole stream is limited by stream timeout if it's given
+	sds, err := ss.Get(context.Background(), "slow.com")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Empty(t, sds)
+}
+
+func TestSonarSearchInitTLS(t *testing.T) {
+	dir := t.TempDir()
+	caPEM, server, _ := writeTestPKI(t, dir)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+	// server requires client certificate issued by the CA
+	addr := startTCPGrpcServer(t, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+
+	ss := NewSonarSearchSbs()
+	require.NoError(t, ss.Init(
+		base.Timeout(time.Second),
+		base.Param(ParamAddress, addr),
+		base.Param(ParamCA, filepath.Join(dir, "ca.pem")),
+		base.Param(ParamCert, filepath.Join(dir, "client.pem")),
+		base.Param(ParamKey, filepath.Join(dir, "client-key.pem")),
+	))
+	sds, err := ss.Get(context.Background(), "user.github.io")
+	require.NoError(t, err)
+	sort.Strings(sds)
+	assert.Equal(t, []string{"abc.user.github.io", "test.user.github.io"}, sds)
+	assert.NoError(t, ss.Close())
+
+	// without client certificate
+	assert.Error(t, NewSonarSearchSbs().Init(
+		base.Timeout(300*time.Millisecond),
+		base.Param(ParamAddress, addr),
+		base.Param(ParamCA, filepath.Join(dir, "ca.pem")),
+	))
+	// server is not trusted without ca
+	assert.Error(t, NewSonarSearchSbs().Init(
+		base.Timeout(300*time.Millisecond),
+		base.Param(ParamAddress, addr),
+		base.Param(ParamCert, filepath.Join(dir, "client.pem")),
+		base.Param(ParamKey, filepath.Join(dir, "client-key.pem")),
+	))
+	// invalid params
+	assert.Error(t, NewSonarSearchSbs().Init(base.Param(ParamCert, filepath.Join(dir, "client.pem"))))
+	assert.Error(t, NewSonarSearchSbs().Init(base.Param(ParamCA, filepath.Join(dir, "client-key.pem"))))
+}
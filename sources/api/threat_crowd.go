@@ -8,6 +8,8 @@ import (
 
 // https://www.threatcrowd.org/searchApi/v2/domain/report/?domain={domain}
 // rate limit: 1 reqs / 10s
+//
+// Deprecated: the api has been retired by AlienVault, use OTX instead
 const NameThreatCrowd = "threatcrowd"
 
 func init() {
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://www.virustotal.com/api/v3/domains/<domain>/subdomains?limit=40&cursor=<cursor>
+// rate limit: 4 req / min, 500 req / day for free user. api key is required in 'x-apikey' header
+const (
+	NameVirusTotal = "virustotal"
+
+	// VirusTotalQPS is the rate limit of free user
+	VirusTotalQPS = 4.0 / 60
+	// VirusTotalKeyEnv is the environment variable of api key used if keys are not given in config
+	VirusTotalKeyEnv = "VT_API_KEY"
+	// VirusTotalPageLimit is the max amount of subdomains in one page
+	VirusTotalPageLimit = 40
+)
+
+func init() {
+	base.MustRegister(NameVirusTotal, NewVirusTotal())
+}
+
+type VirusTotal struct{ base.SDFinder }
+type VirusTotalRsp struct {
+	Data []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"data"`
+	Meta struct {
+		Cursor string `json:"cursor"`
+	} `json:"meta"`
+}
+
+func NewVirusTotal() *VirusTotal {
+	return &VirusTotal{*base.NewSDFinder()}
+}
+
+func (vt *VirusTotal) Init(opts ...base.Option) error {
+	vt.URLbuilder = func(domain string) string {
+		return fmt.Sprintf("https://www.virustotal.com/api/v3/domains/%s/subdomains?limit=%d", domain, VirusTotalPageLimit)
+	}
+	vt.PageURLbuilder = func(domain string, page base.Page) string {
+		if len(page.Cursor) == 0 {
+			return vt.URLbuilder(domain)
+		}
+		return vt.URLbuilder(domain) + "&cursor=" + url.QueryEscape(page.Cursor)
+	}
+	vt.ParsePage = func(content []byte) ([]string, string, error) {
+		var vtrsp VirusTotalRsp
+		if err := json.Unmarshal(content, &vtrsp); err != nil {
+			return nil, "", err
+		}
+		var subdomains []string
+		for _, item := range vtrsp.Data {
+			if item.Type == "domain" && len(item.ID) > 0 {
+				subdomains = append(subdomains, item.ID)
+			}
+		}
+		return subdomains, vtrsp.Meta.Cursor, nil
+	}
+	// default placement of api key, which could be overwritten by given options
+	opts = append([]base.Option{base.KeyPlacement(base.KeyInHeader, "x-apikey", "")}, opts...)
+	if err := vt.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	if vt.Keys.Len() == 0 {
+		// fallback to environment variable if keys are not given
+		if key := os.Getenv(VirusTotalKeyEnv); len(key) > 0 {
+			return base.APIKeys(key)(&vt.SDFinder)
+		}
+		return fmt.Errorf("api key is required for %s, set it in config or %s", NameVirusTotal, VirusTotalKeyEnv)
+	}
+	return nil
+}
+
+func (VirusTotal) Name() string {
+	return NameVirusTotal
+}
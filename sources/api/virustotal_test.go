@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func NewMockVTServer() *httptest.Server {
+	pages := map[string]string{
+		"": `{"data": [{"id": "abc.abc.com", "type": "domain"}, {"id": "mail.abc.com", "type": "domain"}],` +
+			`"meta": {"count": 3, "cursor": "Y3Vyc29yMQ=="}, "links": {"self": "..."}}`,
+		"Y3Vyc29yMQ==": `{"data": [{"id": "test.abc.com", "type": "domain"}], "meta": {"count": 3}}`,
+	}
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("x-apikey") != "vtkey" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"code": "WrongCredentialsError"}}`))
+			return
+		}
+		page, exist := pages[req.URL.Query().Get("cursor")]
+		if !exist {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+}
+
+func TestVirusTotal(t *testing.T) {
+	testSrv := NewMockVTServer()
+	testSrv.Start()
+	defer testSrv.Close()
+
+	testURLBuilder := func(domain string) string {
+		return fmt.Sprintf("%s/api/v3/domains/%s/subdomains?limit=40", testSrv.URL, domain)
+	}
+	vt := NewVirusTotal()
+	require.NoError(t, vt.Init(base.UrlBuilder(testURLBuilder), base.QPS(100), base.APIKeys("vtkey")))
+	subdomains, err := vt.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	exp := []string{
+		"abc.abc.com",
+		"mail.abc.com",
+		"test.abc.com",
+	}
+	assert.Equal(t, exp, subdomains)
+	assert.Equal(t, vt.Stat.DomainsCnt, uint64(1))
+	assert.Equal(t, vt.Stat.SuccessCnt, uint64(1))
+	assert.Equal(t, vt.Stat.PageCnt, uint64(2))
+	assert.Equal(t, vt.Stat.RelatedDomainCnt, uint64(len(exp)))
+
+	// stop at max pages
+	vt = NewVirusTotal()
+	require.NoError(t, vt.Init(base.UrlBuilder(testURLBuilder), base.QPS(100), base.APIKeys("vtkey"), base.MaxPages(1)))
+	subdomains, err = vt.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Len(t, subdomains, 2)
+	assert.Equal(t, vt.Stat.PageLimitCnt, uint64(1))
+
+	// wrong key
+	vt = NewVirusTotal()
+	require.NoError(t, vt.Init(base.UrlBuilder(testURLBuilder), base.APIKeys("wrong")))
+	_, err = vt.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Equal(t, vt.Stat.ErrCnt, uint64(1))
+}
+
+func TestVirusTotalAPIKey(t *testing.T) {
+	t.Setenv(VirusTotalKeyEnv, "")
+	assert.Error(t, NewVirusTotal().Init())
+
+	t.Setenv(VirusTotalKeyEnv, "vtkey")
+	vt := NewVirusTotal()
+	require.NoError(t, vt.Init())
+	assert.Equal(t, 1, vt.Keys.Len())
+	assert.Equal(t, "x-apikey", vt.Keys.Name)
+}
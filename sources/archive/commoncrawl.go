@@ -0,0 +1,218 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://index.commoncrawl.org/collinfo.json lists the crawl collections, E.g.,
+// [{"id": "CC-MAIN-2024-10", "name": "February/March 2024 Index", "cdx-api": "https://index.commoncrawl.org/CC-MAIN-2024-10-index"}]
+// <cdx-api>?url=*.<domain>&output=json&showNumPages=true returns {"pages": 2, "pageSize": 5, "blocks": 7}
+// <cdx-api>?url=*.<domain>&output=json&page=<page> returns one json in each line, E.g., {"urlkey": "...", "url": "http://www.bench.com/"}
+const (
+	NameCommonCrawl = "commoncrawl"
+
+	// CommonCrawlTimeout is the default timeout, the index server is slow for large domains
+	CommonCrawlTimeout = time.Minute
+	// CommonCrawlCollInfoURL lists collections of Common Crawl
+	CommonCrawlCollInfoURL = "https://index.commoncrawl.org/collinfo.json"
+	// DefaultCommonCrawlCollections is the amount of latest collections queried for each domain
+	DefaultCommonCrawlCollections = 3
+
+	// ParamCollections is the param to set amount of latest collections to query
+	ParamCollections = "collections"
+	// CommonCrawlInfoCollection is the key of extra information, collection ids that the domain is found in
+	CommonCrawlInfoCollection = "collection"
+)
+
+func init() {
+	base.MustRegister(NameCommonCrawl, NewCommonCrawl())
+}
+
+type CommonCrawl struct {
+	base.SDFinder
+	CollInfoURL string
+	Collections int // amount of latest collections to query
+
+	mu    sync.Mutex
+	colls []CommonCrawlColl // fetched once and shared by queries
+}
+
+type CommonCrawlColl struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	CDXAPI string `json:"cdx-api"`
+}
+
+type CommonCrawlPages struct {
+	Pages int `json:"pages"`
+}
+
+func NewCommonCrawl() *CommonCrawl {
+	return &CommonCrawl{SDFinder: *base.NewSDFinder(), CollInfoURL: CommonCrawlCollInfoURL}
+}
+
+func (cc *CommonCrawl) Init(opts ...base.Option) error {
+	cc.URLbuilder = func(domain string) string {
+		return "?url=" + url.QueryEscape("*."+domain) + "&output=json"
+	}
+	if err := cc.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	collections, err := cc.IntParam(ParamCollections, DefaultCommonCrawlCollections)
+	if err != nil {
+		return err
+	}
+	cc.Collections = collections
+	return nil
+}
+
+// collections returns the latest collections, collinfo is fetched in the first query
+// and fetched again in next query if it fails
+func (cc *CommonCrawl) collections(ctx context.Context) ([]CommonCrawlColl, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.colls != nil {
+		return cc.colls, nil
+	}
+	content, err := cc.Fetch(ctx, cc.CollInfoURL)
+	if err != nil {
+		return nil, err
+	}
+	var colls []CommonCrawlColl
+	if err := json.Unmarshal(content, &colls); err != nil {
+		return nil, err
+	}
+	// ids are in 'CC-MAIN-<year>-<week>' format, the latest is in front after sorting
+	sort.Slice(colls, func(i, j int) bool {
+		return colls[i].ID > colls[j].ID
+	})
+	if len(colls) > cc.Collections {
+		colls = colls[:cc.Collections]
+	}
+	cc.colls = colls
+	return cc.colls, nil
+}
+
+// notFound is true if index server responds 404, which means there is no capture of the domain
+func notFound(err error) bool {
+	var serr *base.StatusError
+	return errors.As(err, &serr) && serr.Code == http.StatusNotFound
+}
+
+// query fetches all pages of the domain in the collection
+func (cc *CommonCrawl) query(ctx context.Context, coll CommonCrawlColl, domain string) ([]string, error) {
+	queryURL := coll.CDXAPI + cc.URLbuilder(domain)
+	content, err := cc.Fetch(ctx, queryURL+"&showNumPages=true")
+	if err != nil {
+		if notFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pages CommonCrawlPages
+	if err := json.Unmarshal(content, &pages); err != nil {
+		return nil, err
+	}
+	if cc.MaxPages > 0 && pages.Pages > cc.MaxPages {
+		atomic.AddUint64(&cc.Stat.PageLimitCnt, uint64(1))
+		pages.Pages = cc.MaxPages
+	}
+	var hostnames []string
+	for page := 0; page < pages.Pages; page++ {
+		hns, err := cc.FetchStream(ctx, queryURL+"&page="+strconv.Itoa(page), parseCommonCrawl)
+		if err != nil {
+			if notFound(err) {
+				break
+			}
+			return nil, err
+		}
+		atomic.AddUint64(&cc.Stat.PageCnt, uint64(1))
+		hostnames = append(hostnames, hns...)
+	}
+	return hostnames, nil
+}
+
+// parseCommonCrawl reads captures line by line and keeps unique hostnames of urls
+func parseCommonCrawl(r io.Reader) ([]string, error) {
+	var hostnames []string
+	seen := make(map[string]struct{})
+	reader := bufio.NewReader(r)
+	for {
+		line, err := readLine(reader)
+		var capture struct {
+			URL string `json:"url"`
+		}
+		if len(line) > 0 && json.Unmarshal([]byte(line), &capture) == nil {
+			if hostname := Hostname(capture.URL); len(hostname) > 0 {
+				if _, hasseen := seen[hostname]; !hasseen {
+					seen[hostname] = struct{}{}
+					hostnames = append(hostnames, hostname)
+				}
+			}
+		}
+		if err == io.EOF {
+			return hostnames, nil
+		}
+		if err != nil {
+			return hostnames, err
+		}
+	}
+}
+
+// GetWithInfo queries the latest collections one by one and merges hostnames, the ids of
+// collections that the hostname is found in are given in extra information
+func (cc *CommonCrawl) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		cc.RecordStat(subdomains, err)
+	}()
+	colls, err := cc.collections(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := make(map[string][]string) // hostname -> collection ids
+	for _, coll := range colls {
+		hostnames, err := cc.query(ctx, coll, domain)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, hostname := range hostnames {
+			if _, exist := found[hostname]; !exist {
+				subdomains = append(subdomains, hostname)
+			}
+			found[hostname] = append(found[hostname], coll.ID)
+		}
+	}
+	info = make(base.ExInfo)
+	for hostname, ids := range found {
+		info.Set(hostname, CommonCrawlInfoCollection, strings.Join(ids, ","))
+	}
+	return subdomains, info, nil
+}
+
+func (cc *CommonCrawl) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := cc.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
+
+func (cc *CommonCrawl) RelatedMethod() string {
+	return base.FromArchive
+}
+
+func (cc *CommonCrawl) Name() string {
+	return NameCommonCrawl
+}
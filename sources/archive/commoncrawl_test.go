@@ -0,0 +1,114 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func NewMockCommonCrawlServer() *httptest.Server {
+	var srvURL string
+	captures := map[string][]string{
+		"CC-MAIN-2024-10/0": {
+			`{"urlkey": "com,bench,www)/", "url": "http://www.bench.com/"}`,
+			`{"urlkey": "com,bench,www)/a", "url": "https://www.bench.com:443/a"}`,
+			`not json`,
+		},
+		"CC-MAIN-2024-10/1": {`{"urlkey": "com,bench,mail)/", "url": "http://mail.bench.com/"}`},
+		"CC-MAIN-2023-50/0": {`{"urlkey": "com,bench,old)/", "url": "http://old.bench.com/"}`},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/collinfo.json", func(w http.ResponseWriter, req *http.Request) {
+		var colls []string
+		for _, id := range []string{"CC-MAIN-2023-40", "CC-MAIN-2024-10", "CC-MAIN-2023-50"} {
+			colls = append(colls, fmt.Sprintf(`{"id": "%s", "name": "%s", "cdx-api": "%s/%s-index"}`, id, id, srvURL, id))
+		}
+		w.Write([]byte("[" + strings.Join(colls, ",") + "]"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("url") != "*.bench.com" || req.URL.Query().Get("output") != "json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		coll := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), "-index")
+		pages := 0
+		for key := range captures {
+			if strings.HasPrefix(key, coll+"/") {
+				pages++
+			}
+		}
+		if pages == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "No Captures found for: *.bench.com"}`))
+			return
+		}
+		if req.URL.Query().Get("showNumPages") == "true" {
+			fmt.Fprintf(w, `{"pages": %d, "pageSize": 5, "blocks": 7}`, pages)
+			return
+		}
+		w.Write([]byte(strings.Join(captures[coll+"/"+req.URL.Query().Get("page")], "\n")))
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Start()
+	srvURL = srv.URL
+	return srv
+}
+
+func TestCommonCrawl(t *testing.T) {
+	testSrv := NewMockCommonCrawlServer()
+	defer testSrv.Close()
+
+	cc := NewCommonCrawl()
+	cc.CollInfoURL = testSrv.URL + "/collinfo.json"
+	require.NoError(t, cc.Init(base.QPS(100), base.Param(ParamCollections, "2")))
+	assert.Equal(t, 2, cc.Collections)
+	subdomains, info, err := cc.GetWithInfo(context.Background(), "bench.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	exp := []string{
+		"mail.bench.com",
+		"old.bench.com",
+		"www.bench.com",
+	}
+	assert.Equal(t, exp, subdomains)
+	assert.Equal(t, "CC-MAIN-2024-10", info.Get("www.bench.com", CommonCrawlInfoCollection))
+	assert.Equal(t, "CC-MAIN-2023-50", info.Get("old.bench.com", CommonCrawlInfoCollection))
+	assert.Equal(t, cc.Stat.SuccessCnt, uint64(1))
+	assert.Equal(t, cc.Stat.PageCnt, uint64(3))
+	assert.Equal(t, cc.Stat.RelatedDomainCnt, uint64(len(exp)))
+
+	// collinfo is fetched once, not found in any collection
+	cc.CollInfoURL = testSrv.URL + "/not-exist.json"
+	subdomains, err = cc.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, cc.Stat.NotFoundCnt, uint64(1))
+
+	// stop at max pages
+	cc = NewCommonCrawl()
+	cc.CollInfoURL = testSrv.URL + "/collinfo.json"
+	require.NoError(t, cc.Init(base.QPS(100), base.Param(ParamCollections, "1"), base.MaxPages(1)))
+	subdomains, err = cc.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.bench.com"}, subdomains)
+	assert.Equal(t, cc.Stat.PageLimitCnt, uint64(1))
+
+	// failed to fetch collinfo
+	cc = NewCommonCrawl()
+	cc.CollInfoURL = testSrv.URL + "/not-exist.json"
+	require.NoError(t, cc.Init(base.QPS(100)))
+	_, err = cc.Get(context.Background(), "bench.com")
+	assert.Error(t, err)
+	assert.Equal(t, cc.Stat.ErrCnt, uint64(1))
+
+	assert.Error(t, NewCommonCrawl().Init(base.Param(ParamCollections, "0")))
+}
@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://web.archive.org/cdx/search/cdx?url=*.<domain>/*&fl=original&collapse=urlkey
+// one archived url in each line, E.g., 'http://user@www.bench.com:80/index.html'
+const (
+	NameWayback = "wayback"
+
+	// WaybackTimeout is the default timeout, the cdx api is slow for domains with lots of archived urls
+	WaybackTimeout = time.Minute
+	// maxLineBytes is the max length of archived url, longer lines are skipped
+	maxLineBytes = 64 * 1024
+)
+
+func init() {
+	base.MustRegister(NameWayback, NewWayback())
+}
+
+type Wayback struct{ base.SDFinder }
+
+func NewWayback() *Wayback {
+	return &Wayback{*base.NewSDFinder()}
+}
+
+func (wb *Wayback) Init(opts ...base.Option) error {
+	wb.URLbuilder = func(domain string) string {
+		return "https://web.archive.org/cdx/search/cdx?url=" + url.QueryEscape("*."+domain+"/*") +
+			"&fl=original&collapse=urlkey"
+	}
+	wb.ParseStream = parseWayback
+	return wb.SDFinder.Init(opts...)
+}
+
+// parseWayback reads archived urls line by line and keeps unique hostnames, lines that
+// can not be parsed are skipped since archived urls are often malformed
+func parseWayback(r io.Reader) ([]string, error) {
+	var subdomains []string
+	seen := make(map[string]struct{})
+	reader := bufio.NewReader(r)
+	for {
+		line, err := readLine(reader)
+		if hostname := Hostname(line); len(hostname) > 0 {
+			if _, hasseen := seen[hostname]; !hasseen {
+				seen[hostname] = struct{}{}
+				subdomains = append(subdomains, hostname)
+			}
+		}
+		if err == io.EOF {
+			return subdomains, nil
+		}
+		if err != nil {
+			return subdomains, err
+		}
+	}
+}
+
+// readLine returns the next line without line break, lines longer than maxLineBytes are discarded
+func readLine(reader *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if sb.Len()+len(chunk) <= maxLineBytes {
+			sb.Write(chunk)
+		} else {
+			sb.Reset()
+			sb.WriteByte(0) // mark too long line as invalid
+		}
+		if err != nil || !isPrefix {
+			return sb.String(), err
+		}
+	}
+}
+
+// Hostname extracts hostname from archived url without scheme, credential and port,
+// empty string is returned if the url is invalid or the host is an ip
+func Hostname(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if len(rawURL) == 0 {
+		return ""
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if len(hostname) == 0 || net.ParseIP(hostname) != nil || strings.ContainsAny(hostname, " %") {
+		return ""
+	}
+	return hostname
+}
+
+func (wb Wayback) RelatedMethod() string {
+	return base.FromArchive
+}
+
+func (wb Wayback) Name() string {
+	return NameWayback
+}
@@ -0,0 +1,144 @@
+package base
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultAdaptiveDecrease = 0.5
+	// DefaultAdaptiveMinRatio is the ratio of configured qps used as min qps if it's not given
+	DefaultAdaptiveMinRatio = 0.1
+	// DefaultAdaptiveIncreaseRatio is the ratio of max qps used as increase step if it's not given
+	DefaultAdaptiveIncreaseRatio = 0.1
+)
+
+// ErrSlowDown is returned when the body of response contains slow down message of the source
+var ErrSlowDown = errors.New("asked to slow down by source")
+
+// Adaptive adjusts the rate of limiter in AIMD way, the rate is decreased multiplicatively
+// when the source responds 429, timeouts or asks to slow down, and is increased additively
+// after each success until it reaches max rate
+type Adaptive struct {
+	Min      float64  // min qps
+	Max      float64  // max qps, use configured qps if not given
+	Decrease float64  // multiplicative factor in (0, 1) when throttled
+	Increase float64  // additive step after each success
+	SlowDown [][]byte // patterns in response body that indicate throttling
+
+	mu      sync.Mutex
+	limiter *rate.Limiter
+}
+
+// bind sets the limiter to adjust, and fills the defaults base on its configured rate
+func (a *Adaptive) bind(limiter *rate.Limiter) {
+	a.limiter = limiter
+	qps := float64(limiter.Limit())
+	if a.Max <= 0 {
+		a.Max = qps
+	}
+	if a.Min <= 0 {
+		a.Min = qps * DefaultAdaptiveMinRatio
+	}
+	if a.Decrease <= 0 || a.Decrease >= 1 {
+		a.Decrease = DefaultAdaptiveDecrease
+	}
+	if a.Increase <= 0 {
+		a.Increase = a.Max * DefaultAdaptiveIncreaseRatio
+	}
+}
+
+// IsSlowDown returns whether content contains any of the slow down patterns
+func (a *Adaptive) IsSlowDown(content []byte) bool {
+	for _, pattern := range a.SlowDown {
+		if bytes.Contains(content, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Adaptive) set(qps float64) {
+	if qps < a.Min {
+		qps = a.Min
+	}
+	if qps > a.Max {
+		qps = a.Max
+	}
+	a.limiter.SetLimit(rate.Limit(qps))
+}
+
+// OnThrottle decreases the rate, but not lower than min rate
+func (a *Adaptive) OnThrottle() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.set(float64(a.limiter.Limit()) * a.Decrease)
+}
+
+// OnSuccess increases the rate, but not higher than max rate
+func (a *Adaptive) OnSuccess() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.set(float64(a.limiter.Limit()) + a.Increase)
+}
+
+// Limit returns the current effective rate
+func (a *Adaptive) Limit() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return float64(a.limiter.Limit())
+}
+
+// AdaptiveQPS enables adaptive rate limiting, the rate is between min and max qps.
+// The default is used for min or max if it's 0
+func AdaptiveQPS(min, max float64) Option {
+	return func(sdf *SDFinder) error {
+		if min < 0 || max < 0 {
+			return fmt.Errorf("adaptive qps should >= 0")
+		}
+		if max > 0 && min > max {
+			return fmt.Errorf("adaptive min qps should <= max qps")
+		}
+		sdf.adaptive().Min, sdf.adaptive().Max = min, max
+		return nil
+	}
+}
+
+// AdaptiveStep sets the multiplicative decrease factor and additive increase step.
+// The default is used for decrease or increase if it's 0
+func AdaptiveStep(decrease, increase float64) Option {
+	return func(sdf *SDFinder) error {
+		if decrease < 0 || decrease >= 1 {
+			return fmt.Errorf("adaptive decrease should be in (0, 1)")
+		}
+		if increase < 0 {
+			return fmt.Errorf("adaptive increase should >= 0")
+		}
+		sdf.adaptive().Decrease, sdf.adaptive().Increase = decrease, increase
+		return nil
+	}
+}
+
+// SlowDown sets patterns of response body that indicate the source asks to slow down
+func SlowDown(patterns ...string) Option {
+	return func(sdf *SDFinder) error {
+		for _, pattern := range patterns {
+			if len(pattern) == 0 {
+				return fmt.Errorf("empty slow down pattern")
+			}
+			sdf.adaptive().SlowDown = append(sdf.adaptive().SlowDown, []byte(pattern))
+		}
+		return nil
+	}
+}
+
+func (sdf *SDFinder) adaptive() *Adaptive {
+	if sdf.Adaptive == nil {
+		sdf.Adaptive = &Adaptive{}
+	}
+	return sdf.Adaptive
+}
@@ -0,0 +1,65 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptive(t *testing.T) {
+	sdf := NewSDFinder()
+	require.NoError(t, sdf.Init(QPS(10), AdaptiveQPS(2, 0), AdaptiveStep(0.5, 1)))
+	assert.Equal(t, 10.0, sdf.Adaptive.Max)
+	sdf.Adaptive.OnThrottle()
+	assert.Equal(t, 5.0, sdf.Adaptive.Limit())
+	sdf.Adaptive.OnThrottle()
+	sdf.Adaptive.OnThrottle()
+	assert.Equal(t, 2.0, sdf.Adaptive.Limit()) // not lower than min
+	for i := 0; i < 20; i++ {
+		sdf.Adaptive.OnSuccess()
+	}
+	assert.Equal(t, 10.0, sdf.Adaptive.Limit()) // not higher than max
+
+	// defaults base on configured qps
+	sdf = NewSDFinder()
+	require.NoError(t, sdf.Init(QPS(4), AdaptiveQPS(0, 0)))
+	assert.InDelta(t, 0.4, sdf.Adaptive.Min, 1e-9)
+	assert.Equal(t, 4.0, sdf.Adaptive.Max)
+	assert.Equal(t, DefaultAdaptiveDecrease, sdf.Adaptive.Decrease)
+	assert.InDelta(t, 0.4, sdf.Adaptive.Increase, 1e-9)
+
+	sdf = NewSDFinder()
+	assert.Error(t, sdf.Init(QPS(1), AdaptiveQPS(2, 0)))
+}
+
+func TestGetAdaptive(t *testing.T) {
+	var reqCnt int32
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch atomic.AddInt32(&reqCnt, 1) {
+		case 1:
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+		case 2:
+			w.Write([]byte("API count exceeded - Increase Quota with Membership"))
+		default:
+			w.Write([]byte("abc.abc.com"))
+		}
+	}))
+	defer testSrv.Close()
+
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), AdaptiveQPS(10, 0), AdaptiveStep(0.5, 20),
+		SlowDown("API count exceeded"), Retries(2, time.Millisecond))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc.abc.com"}, subdomains)
+	assert.Equal(t, int32(3), reqCnt)
+	assert.Equal(t, uint64(2), sdf.Stat.ThrottleCnt)
+	assert.Equal(t, uint64(2), sdf.Stat.RetryCnt)
+	// 100 -> 50 -> 25 -> 45
+	assert.Equal(t, 45.0, sdf.GetStat().QPS)
+}
@@ -0,0 +1,192 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	KeyInHeader = "header"
+	KeyInQuery  = "query"
+
+	// DefaultKeyCooldown is the duration that a key is not used after the source responds 429
+	DefaultKeyCooldown = time.Minute
+)
+
+// ErrNoAPIKey is returned when all the api keys are disabled, or rate limited when picking without waiting
+var ErrNoAPIKey = errors.New("no available api key")
+
+type apiKey struct {
+	value    string
+	disabled bool      // rejected by 401/403, never used again
+	until    time.Time // rate limited by 429, not used until then
+}
+
+// KeyRing rotates api keys in round-robin, and demotes the key that is rejected
+// or rate limited by the source
+type KeyRing struct {
+	In       string // where the key is placed, KeyInHeader or KeyInQuery
+	Name     string // name of header or query parameter
+	Prefix   string // prepend to the key value, E.g., "Bearer "
+	Cooldown time.Duration
+
+	mu   sync.Mutex
+	keys []*apiKey
+	next int
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{In: KeyInHeader, Cooldown: DefaultKeyCooldown}
+}
+
+// Add adds keys to the ring, the values are also registered to be redacted
+func (kr *KeyRing) Add(values ...string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for _, val := range values {
+		AddSecret(val)
+		kr.keys = append(kr.keys, &apiKey{value: val})
+	}
+}
+
+// Len returns amount of keys in the ring, including demoted keys
+func (kr *KeyRing) Len() int {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return len(kr.keys)
+}
+
+// Pick returns the next available key in round-robin
+func (kr *KeyRing) Pick() (string, error) {
+	key, _, err := kr.pick(time.Now())
+	return key, err
+}
+
+// pick returns the next available key, or the wait until the earliest cooldown ends if all the
+// keys which are not disabled are rate limited. ErrNoAPIKey is returned if all the keys are disabled
+func (kr *KeyRing) pick(now time.Time) (string, time.Duration, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	var wait time.Duration
+	for i := 0; i < len(kr.keys); i++ {
+		key := kr.keys[(kr.next+i)%len(kr.keys)]
+		if key.disabled {
+			continue
+		}
+		if now.Before(key.until) {
+			if d := key.until.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		kr.next = (kr.next + i + 1) % len(kr.keys)
+		return key.value, 0, nil
+	}
+	return "", wait, ErrNoAPIKey
+}
+
+// Wait returns the next available key, it waits until the earliest cooldown ends if all the keys are
+// rate limited, which is bounded by ctx. ErrNoAPIKey is returned without waiting if all the keys are disabled
+func (kr *KeyRing) Wait(ctx context.Context) (string, error) {
+	for {
+		key, wait, err := kr.pick(time.Now())
+		if err == nil || wait == 0 {
+			return key, err
+		}
+		if err := Sleep(ctx, wait); err != nil {
+			return "", err
+		}
+	}
+}
+
+// Available returns whether there is any key which is not disabled
+func (kr *KeyRing) Available() bool {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for _, key := range kr.keys {
+		if !key.disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Demote stops using the key base on response code of the source. The key is disabled
+// if it's rejected (401, 403), and is not used for a while if it's rate limited (429)
+func (kr *KeyRing) Demote(value string, code int) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for _, key := range kr.keys {
+		if key.value != value {
+			continue
+		}
+		switch code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			key.disabled = true
+		case http.StatusTooManyRequests:
+			key.until = time.Now().Add(kr.Cooldown)
+		}
+	}
+}
+
+// Apply places the key in header or query parameter of the request
+func (kr *KeyRing) Apply(req *http.Request, value string) {
+	switch kr.In {
+	case KeyInQuery:
+		query := req.URL.Query()
+		query.Set(kr.Name, kr.Prefix+value)
+		req.URL.RawQuery = query.Encode()
+	default:
+		req.Header.Set(kr.Name, kr.Prefix+value)
+	}
+}
+
+func (sdf *SDFinder) keyRing() *KeyRing {
+	if sdf.Keys == nil {
+		sdf.Keys = NewKeyRing()
+	}
+	return sdf.Keys
+}
+
+// APIKeys adds keys that are rotated for each request
+func APIKeys(values ...string) Option {
+	return func(sdf *SDFinder) error {
+		for _, val := range values {
+			if len(strings.TrimSpace(val)) == 0 {
+				return fmt.Errorf("empty api key")
+			}
+		}
+		sdf.keyRing().Add(values...)
+		return nil
+	}
+}
+
+// KeyPlacement defines where to place the api key, 'in' should be either KeyInHeader or KeyInQuery
+func KeyPlacement(in, name, prefix string) Option {
+	return func(sdf *SDFinder) error {
+		if in != KeyInHeader && in != KeyInQuery {
+			return fmt.Errorf("api key should be placed in %s or %s", KeyInHeader, KeyInQuery)
+		}
+		if len(name) == 0 {
+			return fmt.Errorf("empty api key name")
+		}
+		kr := sdf.keyRing()
+		kr.In, kr.Name, kr.Prefix = in, name, prefix
+		return nil
+	}
+}
+
+func KeyCooldown(cooldown time.Duration) Option {
+	return func(sdf *SDFinder) error {
+		if cooldown <= 0 {
+			return fmt.Errorf("api key cooldown should > 0s")
+		}
+		sdf.keyRing().Cooldown = cooldown
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing(t *testing.T) {
+	kr := NewKeyRing()
+	kr.Add("key1", "key2", "key3")
+	var picked []string
+	for i := 0; i < 4; i++ {
+		key, err := kr.Pick()
+		require.NoError(t, err)
+		picked = append(picked, key)
+	}
+	assert.Equal(t, []string{"key1", "key2", "key3", "key1"}, picked)
+
+	// rejected key is never used again, rate limited key is skipped until cooldown
+	kr.Cooldown = 50 * time.Millisecond
+	kr.Demote("key2", http.StatusUnauthorized)
+	kr.Demote("key3", http.StatusTooManyRequests)
+	for i := 0; i < 2; i++ {
+		key, err := kr.Pick()
+		require.NoError(t, err)
+		assert.Equal(t, "key1", key)
+	}
+	kr.Demote("key1", http.StatusForbidden)
+	_, err := kr.Pick()
+	assert.ErrorIs(t, err, ErrNoAPIKey)
+	time.Sleep(60 * time.Millisecond)
+	key, err := kr.Pick()
+	require.NoError(t, err)
+	assert.Equal(t, "key3", key)
+
+	// wait until the earliest cooldown ends
+	kr = NewKeyRing()
+	kr.Add("wait-key1", "wait-key2")
+	kr.Demote("wait-key1", http.StatusTooManyRequests)
+	kr.Cooldown = 50 * time.Millisecond
+	kr.Demote("wait-key2", http.StatusTooManyRequests)
+	start := time.Now()
+	key, err = kr.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "wait-key2", key)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	kr.Demote("wait-key2", http.StatusTooManyRequests)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = kr.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// not waiting if all the keys are disabled
+	kr.Demote("wait-key1", http.StatusUnauthorized)
+	kr.Demote("wait-key2", http.StatusUnauthorized)
+	assert.False(t, kr.Available())
+	_, err = kr.Wait(context.Background())
+	assert.ErrorIs(t, err, ErrNoAPIKey)
+}
+
+func TestAPIKeysCooldown(t *testing.T) {
+	var reqCnt int
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reqCnt++
+		if reqCnt == 1 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("abc.abc.com"))
+	}))
+	defer testSrv.Close()
+
+	// the only key is rate limited, the retry waits for the cooldown
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), Retries(1, time.Millisecond),
+		KeyPlacement(KeyInHeader, "x-apikey", ""), APIKeys("cooldown-key"), KeyCooldown(50*time.Millisecond))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc.abc.com"}, subdomains)
+	assert.Equal(t, 2, reqCnt)
+}
+
+func TestAPIKeys(t *testing.T) {
+	var got []string
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("x-apikey")
+		if len(key) == 0 {
+			key = req.URL.Query().Get("apikey")
+		}
+		got = append(got, key)
+		if key == "Bearer bad-key" || key == "bad-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("abc.abc.com"))
+	}))
+	defer testSrv.Close()
+
+	// key name is mandatory
+	sdf := NewSDFinder()
+	assert.Error(t, sdf.Init(APIKeys("good-key")))
+
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100),
+		KeyPlacement(KeyInHeader, "x-apikey", "Bearer "), APIKeys("bad-key", "good-key"))
+	// request is sent again with the next key once the key is rejected
+	for i := 0; i < 3; i++ {
+		_, err := sdf.Get(context.Background(), "abc.com")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Bearer bad-key", "Bearer good-key", "Bearer good-key", "Bearer good-key"}, got)
+
+	got = nil
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100),
+		KeyPlacement(KeyInQuery, "apikey", ""), APIKeys("bad-key"))
+	_, err := sdf.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	_, err = sdf.Get(context.Background(), "abc.com")
+	assert.ErrorIs(t, err, ErrNoAPIKey)
+	assert.Equal(t, []string{"bad-key"}, got)
+}
+
+func TestRedact(t *testing.T) {
+	AddSecret("s3cr3t/key")
+	assert.Equal(t, "key="+redacted+"&q=abc.com", Redact("key=s3cr3t%2Fkey&q=abc.com"))
+	assert.Equal(t, "header "+redacted, Redact("header s3cr3t/key"))
+
+	// the key placed in url should not show up in error
+	sdf := newTestSDFinder(t, "http://127.0.0.1:0", KeyPlacement(KeyInQuery, "key", ""), APIKeys("s3cr3t/key"))
+	_, err := sdf.Get(context.Background(), "abc.com")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+	assert.Contains(t, err.Error(), redacted)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package base
+
+// lockFile is a no-op where flock is not supported, ledger is only guarded within the process
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
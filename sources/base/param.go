@@ -0,0 +1,69 @@
+package base
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Param sets source specific parameter, which is read by the source in Init
+func Param(key, val string) Option {
+	return func(sdf *SDFinder) error {
+		if len(strings.TrimSpace(key)) == 0 {
+			return fmt.Errorf("empty param key")
+		}
+		if sdf.Params == nil {
+			sdf.Params = make(map[string]string)
+		}
+		sdf.Params[key] = val
+		return nil
+	}
+}
+
+// StringParam returns value of parameter, or def if it's not given
+func (sdf *SDFinder) StringParam(key, def string) string {
+	if val, exist := sdf.Params[key]; exist && len(val) > 0 {
+		return val
+	}
+	return def
+}
+
+// IntParam returns value of parameter as positive integer, or def if it's not given
+func (sdf *SDFinder) IntParam(key string, def int) (int, error) {
+	val, exist := sdf.Params[key]
+	if !exist || len(val) == 0 {
+		return def, nil
+	}
+	num, err := strconv.Atoi(val)
+	if err != nil || num <= 0 {
+		return 0, fmt.Errorf("param %q should be positive integer, got %q", key, val)
+	}
+	return num, nil
+}
+
+// BoolParam returns value of parameter as boolean, or def if it's not given
+func (sdf *SDFinder) BoolParam(key string, def bool) (bool, error) {
+	val, exist := sdf.Params[key]
+	if !exist || len(val) == 0 {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("param %q should be boolean, got %q", key, val)
+	}
+	return b, nil
+}
+
+// DurationParam returns value of parameter as positive duration such as '30s', or def if it's not given
+func (sdf *SDFinder) DurationParam(key string, def time.Duration) (time.Duration, error) {
+	val, exist := sdf.Params[key]
+	if !exist || len(val) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("param %q should be positive duration, got %q", key, val)
+	}
+	return d, nil
+}
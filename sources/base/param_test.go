@@ -0,0 +1,52 @@
+package base
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParam(t *testing.T) {
+	sdf := NewSDFinder()
+	require.NoError(t, sdf.Init(Param("collections", "3"), Param("index", "cc"), Param("invalid", "-1")))
+	assert.Equal(t, "cc", sdf.StringParam("index", "default"))
+	assert.Equal(t, "default", sdf.StringParam("not-exist", "default"))
+	num, err := sdf.IntParam("collections", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, num)
+	num, err = sdf.IntParam("not-exist", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, num)
+	_, err = sdf.IntParam("index", 1)
+	assert.Error(t, err)
+	_, err = sdf.IntParam("invalid", 1)
+	assert.Error(t, err)
+
+	assert.Error(t, sdf.Init(Param(" ", "val")))
+}
+
+func TestParamBoolDuration(t *testing.T) {
+	sdf := NewSDFinder()
+	require.NoError(t, sdf.Init(Param("plaintext", "true"), Param("keepalive", "10s"), Param("invalid", "-1s")))
+	b, err := sdf.BoolParam("plaintext", false)
+	require.NoError(t, err)
+	assert.True(t, b)
+	b, err = sdf.BoolParam("not-exist", true)
+	require.NoError(t, err)
+	assert.True(t, b)
+	_, err = sdf.BoolParam("keepalive", false)
+	assert.Error(t, err)
+
+	d, err := sdf.DurationParam("keepalive", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, d)
+	d, err = sdf.DurationParam("not-exist", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+	_, err = sdf.DurationParam("plaintext", time.Minute)
+	assert.Error(t, err)
+	_, err = sdf.DurationParam("invalid", time.Minute)
+	assert.Error(t, err)
+}
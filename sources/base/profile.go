@@ -0,0 +1,120 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	RotatePerRequest = "request"
+	RotatePerWorker  = "worker"
+
+	ChromeUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36"
+	FirefoxUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:143.0) Gecko/20100101 Firefox/143.0"
+	SafariUserAgent  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.6 Safari/605.1.15"
+)
+
+const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+
+// BuiltinProfiles are headers sent by common browsers, which could be referred by name in config
+var BuiltinProfiles = map[string]http.Header{
+	"chrome": {
+		"User-Agent":         {ChromeUserAgent},
+		"Accept":             {browserAccept},
+		"Accept-Language":    {"en-US,en;q=0.9"},
+		"Sec-Ch-Ua-Mobile":   {"?0"},
+		"Sec-Ch-Ua-Platform": {`"Windows"`},
+	},
+	"firefox": {
+		"User-Agent":      {FirefoxUserAgent},
+		"Accept":          {browserAccept},
+		"Accept-Language": {"en-US,en;q=0.5"},
+	},
+	"safari": {
+		"User-Agent":      {SafariUserAgent},
+		"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+		"Accept-Language": {"en-US,en;q=0.9"},
+	},
+}
+
+// BuiltinProfileNames returns names of builtin profiles in order
+func BuiltinProfileNames() []string {
+	var names []string
+	for name := range BuiltinProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type workerCtxKey struct{}
+
+// WithWorker puts the id of worker that handles the query in context
+func WithWorker(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, workerCtxKey{}, id)
+}
+
+// WorkerFromContext returns the id of worker in context, false if it's not given
+func WorkerFromContext(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(workerCtxKey{}).(int)
+	return id, ok
+}
+
+// HeaderProfiles rotates sets of headers such as User-Agent and Accept-Language, either for
+// each request or for each worker so that the same worker always looks like the same browser
+type HeaderProfiles struct {
+	Rotate string // RotatePerRequest or RotatePerWorker
+
+	profiles []http.Header
+	next     uint64
+}
+
+func NewHeaderProfiles(rotate string, profiles ...http.Header) (*HeaderProfiles, error) {
+	if len(rotate) == 0 {
+		rotate = RotatePerRequest
+	}
+	if rotate != RotatePerRequest && rotate != RotatePerWorker {
+		return nil, fmt.Errorf("header profiles should be rotated per %s or %s", RotatePerRequest, RotatePerWorker)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no header profile given")
+	}
+	for _, profile := range profiles {
+		if len(profile) == 0 {
+			return nil, fmt.Errorf("empty header profile")
+		}
+	}
+	return &HeaderProfiles{Rotate: rotate, profiles: profiles}, nil
+}
+
+// Pick selects the profile for the request, the first worker is used if worker is not in ctx
+func (hp *HeaderProfiles) Pick(ctx context.Context) http.Header {
+	if hp.Rotate == RotatePerWorker {
+		id, _ := WorkerFromContext(ctx)
+		return hp.profiles[id%len(hp.profiles)]
+	}
+	return hp.profiles[(atomic.AddUint64(&hp.next, 1)-1)%uint64(len(hp.profiles))]
+}
+
+// apply sets headers of the picked profile, headers already given by source are not overwritten
+func (hp *HeaderProfiles) apply(ctx context.Context, req *http.Request) {
+	for key, vals := range hp.Pick(ctx) {
+		if len(req.Header.Values(key)) == 0 {
+			req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), vals...)
+		}
+	}
+}
+
+// HeaderProfile rotates given header profiles for requests
+func HeaderProfile(hp *HeaderProfiles) Option {
+	return func(sdf *SDFinder) error {
+		if hp == nil {
+			return fmt.Errorf("empty header profiles")
+		}
+		sdf.Profiles = hp
+		return nil
+	}
+}
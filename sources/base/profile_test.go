@@ -0,0 +1,75 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHeaderProfiles(t *testing.T) {
+	hp, err := NewHeaderProfiles("", BuiltinProfiles["chrome"])
+	require.NoError(t, err)
+	assert.Equal(t, RotatePerRequest, hp.Rotate)
+	_, err = NewHeaderProfiles("domain", BuiltinProfiles["chrome"])
+	assert.Error(t, err)
+	_, err = NewHeaderProfiles(RotatePerWorker)
+	assert.Error(t, err)
+	_, err = NewHeaderProfiles(RotatePerWorker, http.Header{})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"chrome", "firefox", "safari"}, BuiltinProfileNames())
+}
+
+func TestHeaderProfiles(t *testing.T) {
+	var mu sync.Mutex
+	var uas []string
+	var accepts []string
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		uas = append(uas, req.Header.Get("User-Agent"))
+		accepts = append(accepts, req.Header.Get("Accept"))
+		w.Write([]byte("abc.abc.com"))
+	}))
+	defer testSrv.Close()
+	reset := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		uas, accepts = nil, nil
+	}
+
+	// rotate per request
+	hp, err := NewHeaderProfiles(RotatePerRequest, BuiltinProfiles["chrome"], BuiltinProfiles["firefox"])
+	require.NoError(t, err)
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), HeaderProfile(hp))
+	for i := 0; i < 3; i++ {
+		_, err := sdf.Get(context.Background(), "abc.com")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{ChromeUserAgent, FirefoxUserAgent, ChromeUserAgent}, uas)
+
+	// rotate per worker, the same worker always uses the same profile
+	reset()
+	hp, err = NewHeaderProfiles(RotatePerWorker, BuiltinProfiles["chrome"], BuiltinProfiles["firefox"], BuiltinProfiles["safari"])
+	require.NoError(t, err)
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), HeaderProfile(hp))
+	for _, worker := range []int{2, 2, 1, 0} {
+		_, err := sdf.Get(WithWorker(context.Background(), worker), "abc.com")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{SafariUserAgent, SafariUserAgent, FirefoxUserAgent, ChromeUserAgent}, uas)
+
+	// headers given by source are not overwritten
+	reset()
+	hp, err = NewHeaderProfiles(RotatePerRequest, BuiltinProfiles["chrome"])
+	require.NoError(t, err)
+	sdf = newTestSDFinder(t, testSrv.URL, Header("Accept", "application/json"), HeaderProfile(hp))
+	_, err = sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ChromeUserAgent}, uas)
+	assert.Equal(t, []string{"application/json"}, accepts)
+}
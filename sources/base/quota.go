@@ -0,0 +1,230 @@
+package base
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	quotaDayFmt   = "2006-01-02"
+	quotaMonthFmt = "2006-01"
+)
+
+// ErrQuotaExhausted is returned without sending request when the quota of source is spent
+var ErrQuotaExhausted = errors.New("quota exhausted")
+
+// Usage is the amount of calls of one source in current day and month
+type Usage struct {
+	Day      string `json:"day"` // in timezone of the quota
+	DayCnt   int    `json:"day_count"`
+	Month    string `json:"month"`
+	MonthCnt int    `json:"month_count"`
+}
+
+// roll resets the counts if day or month has changed
+func (u *Usage) roll(now time.Time) {
+	if day := now.Format(quotaDayFmt); u.Day != day {
+		u.Day, u.DayCnt = day, 0
+	}
+	if month := now.Format(quotaMonthFmt); u.Month != month {
+		u.Month, u.MonthCnt = month, 0
+	}
+}
+
+// Ledger stores usage of sources in json file, which is shared across sources and runs
+type Ledger struct {
+	path  string
+	mu    sync.Mutex
+	usage map[string]*Usage
+}
+
+var ledgers = struct {
+	sync.Mutex
+	opened map[string]*Ledger
+}{opened: make(map[string]*Ledger)}
+
+// OpenLedger returns the ledger of given path, which is loaded from file only once
+// so that all the sources using the same path share the same ledger
+func OpenLedger(path string) (*Ledger, error) {
+	ledgers.Lock()
+	defer ledgers.Unlock()
+	if l, exist := ledgers.opened[path]; exist {
+		return l, nil
+	}
+	l, err := LoadLedger(path)
+	if err != nil {
+		return nil, err
+	}
+	ledgers.opened[path] = l
+	return l, nil
+}
+
+// LoadLedger loads the ledger from file, it's empty if the file does not exist
+func LoadLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path, usage: make(map[string]*Usage)}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// load reads usage from file, it's empty if the file does not exist
+func (l *Ledger) load() error {
+	usage := make(map[string]*Usage)
+	buf, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			l.usage = usage
+			return nil
+		}
+		return fmt.Errorf("read quota ledger %q error: %v", l.path, err)
+	}
+	if err := json.Unmarshal(buf, &usage); err != nil {
+		return fmt.Errorf("unmarshal quota ledger %q error: %v", l.path, err)
+	}
+	l.usage = usage
+	return nil
+}
+
+// Path returns file path of the ledger
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Usage returns the usage of source at given time
+func (l *Ledger) Usage(name string, now time.Time) Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var u Usage
+	if stored, exist := l.usage[name]; exist {
+		u = *stored
+	}
+	u.roll(now)
+	return u
+}
+
+// Names returns names of sources recorded in the ledger
+func (l *Ledger) Names() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var names []string
+	for name := range l.usage {
+		names = append(names, name)
+	}
+	return names
+}
+
+// take consumes one call of source if it does not exceed the limits, and saves the ledger.
+// The file is locked and reloaded before counting, so that the usage is not overwritten by other processes
+func (l *Ledger) take(name string, now time.Time, daily, monthly int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("create quota ledger dir %q error: %v", filepath.Dir(l.path), err)
+	}
+	unlock, err := lockFile(l.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("lock quota ledger %q error: %v", l.path, err)
+	}
+	defer unlock()
+	if err := l.load(); err != nil {
+		return err
+	}
+	u, exist := l.usage[name]
+	if !exist {
+		u = &Usage{}
+		l.usage[name] = u
+	}
+	u.roll(now)
+	if (daily > 0 && u.DayCnt >= daily) || (monthly > 0 && u.MonthCnt >= monthly) {
+		return ErrQuotaExhausted
+	}
+	u.DayCnt++
+	u.MonthCnt++
+	if err := l.save(); err != nil {
+		return fmt.Errorf("save quota ledger %q error: %v", l.path, err)
+	}
+	return nil
+}
+
+// save writes to temp file and renames it, so the ledger is not broken if the process is killed
+func (l *Ledger) save() error {
+	buf, err := json.MarshalIndent(l.usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+// Quota limits the calls of one source per day and per month, usage is recorded in ledger
+type Quota struct {
+	Name     string // key in ledger
+	Daily    int    // no limit if 0
+	Monthly  int    // no limit if 0
+	Location *time.Location
+	Ledger   *Ledger
+}
+
+func (q *Quota) now() time.Time {
+	if q.Location == nil {
+		return time.Now().UTC()
+	}
+	return time.Now().In(q.Location)
+}
+
+// Take consumes one call, ErrQuotaExhausted is returned if the quota is spent
+func (q *Quota) Take() error {
+	return q.Ledger.take(q.Name, q.now(), q.Daily, q.Monthly)
+}
+
+// Remaining returns remaining calls in current day and month, -1 means no limit
+func (q *Quota) Remaining() (day, month int) {
+	u := q.Ledger.Usage(q.Name, q.now())
+	day, month = -1, -1
+	if q.Daily > 0 {
+		day = max(q.Daily-u.DayCnt, 0)
+	}
+	if q.Monthly > 0 {
+		month = max(q.Monthly-u.MonthCnt, 0)
+	}
+	return day, month
+}
+
+// Exhausted returns whether there is no remaining calls
+func (q *Quota) Exhausted() bool {
+	day, month := q.Remaining()
+	return day == 0 || month == 0
+}
+
+func WithQuota(q *Quota) Option {
+	return func(sdf *SDFinder) error {
+		if q.Daily < 0 || q.Monthly < 0 {
+			return fmt.Errorf("quota should >= 0")
+		}
+		if q.Ledger == nil {
+			return fmt.Errorf("quota ledger should be given")
+		}
+		sdf.Quota = q
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sdfinder", "quota.json")
+	ledger, err := LoadLedger(path)
+	require.NoError(t, err)
+	now := time.Date(2022, 5, 31, 23, 0, 0, 0, time.UTC)
+	require.NoError(t, ledger.take("test", now, 2, 3))
+	require.NoError(t, ledger.take("test", now, 2, 3))
+	assert.ErrorIs(t, ledger.take("test", now, 2, 3), ErrQuotaExhausted)
+
+	// usage is shared across runs
+	ledger, err = LoadLedger(path)
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Day: "2022-05-31", DayCnt: 2, Month: "2022-05", MonthCnt: 2}, ledger.Usage("test", now))
+	assert.Equal(t, []string{"test"}, ledger.Names())
+
+	// daily count resets in next day, while monthly count resets in next month
+	nextDay := now.Add(2 * time.Hour)
+	assert.Equal(t, Usage{Day: "2022-06-01", DayCnt: 0, Month: "2022-06", MonthCnt: 0}, ledger.Usage("test", nextDay))
+	sameMonth := time.Date(2022, 5, 30, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, ledger.take("test", sameMonth, 2, 3))
+	assert.ErrorIs(t, ledger.take("test", sameMonth, 2, 3), ErrQuotaExhausted)
+
+	// shared ledger for the same path
+	l1, err := OpenLedger(path)
+	require.NoError(t, err)
+	l2, err := OpenLedger(path)
+	require.NoError(t, err)
+	assert.Same(t, l1, l2)
+}
+
+func TestLedgerMultiProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	now := time.Date(2022, 5, 31, 23, 0, 0, 0, time.UTC)
+	// ledgers loaded separately act as different processes sharing the file
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		ledger, err := LoadLedger(path)
+		require.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, ledger.take("test", now, 0, 0))
+			}
+		}()
+	}
+	wg.Wait()
+	ledger, err := LoadLedger(path)
+	require.NoError(t, err)
+	assert.Equal(t, 40, ledger.Usage("test", now).DayCnt)
+	// no temp file is left
+	tmps, err := filepath.Glob(path + ".*.tmp")
+	require.NoError(t, err)
+	assert.Empty(t, tmps)
+}
+
+func TestGetQuota(t *testing.T) {
+	var reqCnt int32
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&reqCnt, 1)
+		w.Write([]byte("abc.abc.com"))
+	}))
+	defer testSrv.Close()
+
+	ledger, err := LoadLedger(filepath.Join(t.TempDir(), "quota.json"))
+	require.NoError(t, err)
+	quota := &Quota{Name: "test", Daily: 2, Ledger: ledger}
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), WithQuota(quota))
+	for i := 0; i < 3; i++ {
+		_, err = sdf.Get(context.Background(), "abc.com")
+		if i < 2 {
+			assert.NoError(t, err)
+			continue
+		}
+		assert.ErrorIs(t, err, ErrQuotaExhausted)
+	}
+	assert.Equal(t, int32(2), reqCnt)
+	assert.Equal(t, uint64(3), sdf.Stat.DomainsCnt)
+	assert.Equal(t, uint64(2), sdf.Stat.SuccessCnt)
+	assert.Equal(t, uint64(1), sdf.Stat.QuotaSkipCnt)
+	assert.Equal(t, uint64(0), sdf.Stat.ErrCnt)
+	day, month := quota.Remaining()
+	assert.Equal(t, 0, day)
+	assert.Equal(t, -1, month)
+	assert.True(t, quota.Exhausted())
+}
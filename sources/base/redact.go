@@ -0,0 +1,62 @@
+package base
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// secrets stores values such as api keys that should never show up in logs or errors
+var secrets = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+// AddSecret registers value to be replaced by Redact
+func AddSecret(value string) {
+	if len(value) == 0 {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, s := range secrets.values {
+		if s == value {
+			return
+		}
+	}
+	secrets.values = append(secrets.values, value)
+}
+
+// Redact replaces all the registered secrets in s
+func Redact(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, secret := range secrets.values {
+		s = strings.ReplaceAll(s, secret, redacted)
+		// the value might be escaped when it's placed in query parameters
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			s = strings.ReplaceAll(s, escaped, redacted)
+		}
+	}
+	return s
+}
+
+// RedactErr removes registered secrets from err while keeping the type of
+// *url.Error so that it can still be classified by IsTimeout
+func RedactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL = Redact(uerr.URL)
+		return err
+	}
+	if msg := Redact(err.Error()); msg != err.Error() {
+		return errors.New(msg)
+	}
+	return err
+}
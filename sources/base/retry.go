@@ -0,0 +1,190 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultRetryMultiplier = 2.0
+	// DefaultMaxRetryAfter caps the wait of 'Retry-After' if max interval is not given
+	DefaultMaxRetryAfter = time.Minute
+)
+
+// StatusError is returned when the source responds with non 200 status code
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration // parsed from 'Retry-After' header, 0 if not given
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("get rsp code: %d", e.Code)
+}
+
+func newStatusError(rsp *http.Response) *StatusError {
+	return &StatusError{Code: rsp.StatusCode, RetryAfter: ParseRetryAfter(rsp.Header.Get("Retry-After"), time.Now())}
+}
+
+// ParseRetryAfter parses value of 'Retry-After' header in either seconds or http date
+func ParseRetryAfter(val string, now time.Time) time.Duration {
+	if len(val) == 0 {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(val); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// RetryPolicy decides whether to retry a failed request and how long to wait before retrying
+type RetryPolicy struct {
+	Times       int           // max retry times, not retry if 0
+	Interval    time.Duration // wait before the first retry
+	MaxInterval time.Duration // cap of each wait, no cap if 0
+	Multiplier  float64       // wait is multiplied after each retry, fixed interval if 1
+	Jitter      float64       // randomize each wait by +/- ratio in [0, 1]
+	MaxElapsed  time.Duration // give up if total time including the next wait exceeds it, no cap if 0
+	Statuses    []int         // retryable status codes, 429 and 5xx if not given
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Multiplier: DefaultRetryMultiplier}
+}
+
+// Retryable classifies err, only timeout, connection error, slow down and retryable status codes are retried.
+// Errors such as canceled context, 4xx or parsing error are not retried since retrying won't help
+func (rp RetryPolicy) Retryable(err error) bool {
+	if err == nil || errors.Is(err, ErrCanceled) || errors.Is(err, ErrNoAPIKey) || errors.Is(err, ErrQuotaExhausted) ||
+		errors.Is(err, ErrResponseTooLarge) {
+		return false
+	}
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		if len(rp.Statuses) == 0 {
+			return serr.Code == http.StatusTooManyRequests || serr.Code >= http.StatusInternalServerError
+		}
+		for _, code := range rp.Statuses {
+			if code == serr.Code {
+				return true
+			}
+		}
+		return false
+	}
+	if IsTimeout(err) || errors.Is(err, ErrSlowDown) {
+		return true
+	}
+	// temporary dns failure, E.g., SERVFAIL
+	var derr *net.DNSError
+	if errors.As(err, &derr) {
+		return derr.IsTemporary
+	}
+	// only network errors of the transport are retried, while errors such as tls verification failure
+	// or unsupported scheme are not
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		var nerr net.Error
+		return errors.As(uerr.Err, &nerr) || errors.Is(uerr.Err, io.EOF) || isConnErr(uerr.Err)
+	}
+	return isConnErr(err)
+}
+
+// isConnErr returns whether the connection is broken while reading response
+func isConnErr(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Backoff returns the wait before n-th retry, 'Retry-After' in err is honored if it's given,
+// which is capped by max interval, or DefaultMaxRetryAfter if max interval is not given
+func (rp RetryPolicy) Backoff(n int, err error) time.Duration {
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.RetryAfter > 0 {
+		maxWait := rp.MaxInterval
+		if maxWait <= 0 {
+			maxWait = DefaultMaxRetryAfter
+		}
+		return min(serr.RetryAfter, maxWait)
+	}
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(rp.Interval) * math.Pow(multiplier, float64(n-1))
+	if rp.MaxInterval > 0 && wait > float64(rp.MaxInterval) {
+		wait = float64(rp.MaxInterval)
+	}
+	if rp.Jitter > 0 {
+		wait *= 1 + rp.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
+
+func Retries(retries int, interval time.Duration) Option {
+	return func(sdf *SDFinder) error {
+		if retries < 0 {
+			return fmt.Errorf("retries should >= 0")
+		}
+		if retries > 0 && interval <= 0 {
+			return fmt.Errorf("retries interval should >= 0 when retries > 0")
+		}
+		sdf.Retry.Times = retries
+		sdf.Retry.Interval = interval
+		return nil
+	}
+}
+
+// Backoff sets exponential backoff between retries
+func Backoff(multiplier float64, maxInterval time.Duration, jitter float64) Option {
+	return func(sdf *SDFinder) error {
+		if multiplier < 1 {
+			return fmt.Errorf("retries multiplier should >= 1")
+		}
+		if maxInterval < 0 {
+			return fmt.Errorf("retries max interval should >= 0")
+		}
+		if jitter < 0 || jitter > 1 {
+			return fmt.Errorf("retries jitter should be in [0, 1]")
+		}
+		sdf.Retry.Multiplier = multiplier
+		sdf.Retry.MaxInterval = maxInterval
+		sdf.Retry.Jitter = jitter
+		return nil
+	}
+}
+
+// RetryMaxElapsed caps the total time spent on one request including retries
+func RetryMaxElapsed(maxElapsed time.Duration) Option {
+	return func(sdf *SDFinder) error {
+		if maxElapsed <= 0 {
+			return fmt.Errorf("retries max elapsed should > 0s")
+		}
+		sdf.Retry.MaxElapsed = maxElapsed
+		return nil
+	}
+}
+
+func RetryStatuses(codes ...int) Option {
+	return func(sdf *SDFinder) error {
+		for _, code := range codes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("invalid retry status code: %d", code)
+			}
+		}
+		sdf.Retry.Statuses = codes
+		return nil
+	}
+}
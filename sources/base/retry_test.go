@@ -0,0 +1,147 @@
+package base
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 5, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, ParseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, ParseRetryAfter("Tue, 31 May 2022 00:00:30 GMT", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("Mon, 30 May 2022 00:00:30 GMT", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("", now))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	rp := RetryPolicy{Times: 5, Interval: time.Second, MaxInterval: 5 * time.Second, Multiplier: 2}
+	var waits []time.Duration
+	for n := 1; n <= 5; n++ {
+		waits = append(waits, rp.Backoff(n, errors.New("err")))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, waits)
+	assert.Equal(t, 3*time.Second, rp.Backoff(1, &StatusError{Code: 429, RetryAfter: 3 * time.Second}))
+	// 'Retry-After' is capped
+	assert.Equal(t, 5*time.Second, rp.Backoff(1, &StatusError{Code: 429, RetryAfter: time.Minute}))
+	assert.Equal(t, DefaultMaxRetryAfter, RetryPolicy{}.Backoff(1, &StatusError{Code: 429, RetryAfter: time.Hour}))
+
+	rp.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		wait := rp.Backoff(2, errors.New("err"))
+		assert.GreaterOrEqual(t, wait, time.Second)
+		assert.LessOrEqual(t, wait, 3*time.Second)
+	}
+
+	assert.True(t, rp.Retryable(&StatusError{Code: http.StatusTooManyRequests}))
+	assert.True(t, rp.Retryable(&StatusError{Code: http.StatusBadGateway}))
+	assert.False(t, rp.Retryable(&StatusError{Code: http.StatusNotFound}))
+	assert.False(t, rp.Retryable(ErrNoAPIKey))
+	assert.False(t, rp.Retryable(errors.New("parse error")))
+	assert.True(t, rp.Retryable(&net.DNSError{Err: "server misbehaving", IsTemporary: true}))
+	assert.False(t, rp.Retryable(&net.DNSError{Err: "no such host", IsNotFound: true}))
+	// only network errors of transport are retried
+	assert.True(t, rp.Retryable(&url.Error{Op: "Get", URL: "http://abc.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}))
+	assert.True(t, rp.Retryable(&url.Error{Op: "Get", URL: "http://abc.com", Err: io.EOF}))
+	assert.True(t, rp.Retryable(fmt.Errorf("read body: %w", io.ErrUnexpectedEOF)))
+	assert.True(t, rp.Retryable(fmt.Errorf("read body: %w", syscall.ECONNRESET)))
+	assert.False(t, rp.Retryable(&url.Error{Op: "Get", URL: "http://abc.com", Err: x509.UnknownAuthorityError{}}))
+	assert.False(t, rp.Retryable(&url.Error{Op: "Get", URL: "ftp://abc.com", Err: errors.New("unsupported protocol scheme")}))
+	rp.Statuses = []int{http.StatusNotFound}
+	assert.True(t, rp.Retryable(&StatusError{Code: http.StatusNotFound}))
+	assert.False(t, rp.Retryable(&StatusError{Code: http.StatusBadGateway}))
+}
+
+func newStatusSeqServer(reqCnt *int32, codes ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := int(atomic.AddInt32(reqCnt, 1))
+		if n <= len(codes) && codes[n-1] != http.StatusOK {
+			if codes[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "120")
+			}
+			http.Error(w, "error", codes[n-1])
+			return
+		}
+		w.Write([]byte("abc.abc.com"))
+	}))
+}
+
+func TestGetRetries(t *testing.T) {
+	// stop retrying once succeed
+	var reqCnt int32
+	testSrv := newStatusSeqServer(&reqCnt, http.StatusServiceUnavailable, http.StatusOK)
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), Retries(3, time.Millisecond))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc.abc.com"}, subdomains)
+	assert.Equal(t, int32(2), reqCnt)
+	assert.Equal(t, uint64(1), sdf.Stat.RetryCnt)
+	testSrv.Close()
+
+	// 4xx is not retried
+	reqCnt = 0
+	testSrv = newStatusSeqServer(&reqCnt, http.StatusNotFound, http.StatusOK)
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), Retries(3, time.Millisecond))
+	_, err = sdf.Get(context.Background(), "abc.com")
+	var serr *StatusError
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, http.StatusNotFound, serr.Code)
+	assert.Equal(t, int32(1), reqCnt)
+	assert.Equal(t, uint64(0), sdf.Stat.RetryCnt)
+	assert.Equal(t, uint64(1), sdf.Stat.ErrCnt)
+	testSrv.Close()
+
+	// give up if waiting 'Retry-After' exceeds max elapsed
+	reqCnt = 0
+	testSrv = newStatusSeqServer(&reqCnt, http.StatusTooManyRequests, http.StatusOK)
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), Retries(3, time.Millisecond), RetryMaxElapsed(time.Second))
+	_, err = sdf.Get(context.Background(), "abc.com")
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, 120*time.Second, serr.RetryAfter)
+	assert.Equal(t, int32(1), reqCnt)
+	testSrv.Close()
+
+	// 'Retry-After' is capped by max interval
+	reqCnt = 0
+	testSrv = newStatusSeqServer(&reqCnt, http.StatusTooManyRequests, http.StatusOK)
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), Retries(3, time.Millisecond), Backoff(2, 10*time.Millisecond, 0))
+	_, err = sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), reqCnt)
+	testSrv.Close()
+
+	// tls verification failure is not retried
+	reqCnt = 0
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&reqCnt, 1)
+	}))
+	sdf = newTestSDFinder(t, tlsSrv.URL, QPS(100), Retries(3, time.Millisecond))
+	_, err = sdf.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(0), sdf.Stat.RetryCnt)
+	tlsSrv.Close()
+
+	// retry all the times and fail
+	reqCnt = 0
+	testSrv = newStatusSeqServer(&reqCnt, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), Retries(2, time.Millisecond), Backoff(2, 0, 0))
+	_, err = sdf.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), reqCnt)
+	assert.Equal(t, uint64(2), sdf.Stat.RetryCnt)
+	testSrv.Close()
+}
@@ -0,0 +1,30 @@
+package base
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// Request is sent by DoRequest and FetchRequest, which is used by sources sending
+// multiple requests with different methods in one query
+type Request struct {
+	Method string // method of SDFinder if not given
+	URL    string
+	Body   []byte
+	Header http.Header // overwrite headers of SDFinder with the same key
+}
+
+// Session returns a copy of finder whose client keeps cookies in its own jar, the rate limiter,
+// keys, proxies and statistic are shared with the finder. It's used by sources that need cookies
+// from previous responses in one query, E.g., csrf token, without mixing up cookies between queries
+func (sdf *SDFinder) Session() (*SDFinder, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := *sdf.Client
+	client.Jar = jar
+	session := *sdf
+	session.Client = &client
+	return &session, nil
+}
@@ -0,0 +1,63 @@
+package base
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: req.URL.Query().Get("token")})
+			w.Write([]byte("ok"))
+		case http.MethodPost:
+			cookie, err := req.Cookie("token")
+			if err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+			assert.Equal(t, "test-agent", req.Header.Get("User-Agent"))
+			w.Write([]byte(cookie.Value + ":" + string(body)))
+		}
+	}))
+	defer testSrv.Close()
+
+	sdf := NewSDFinder()
+	require.NoError(t, sdf.Init(QPS(100), Header("User-Agent", "test-agent")))
+	post := Request{
+		Method: http.MethodPost,
+		URL:    testSrv.URL,
+		Body:   []byte("q=abc.com"),
+		Header: http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+	}
+	s1, err := sdf.Session()
+	require.NoError(t, err)
+	s2, err := sdf.Session()
+	require.NoError(t, err)
+	_, err = s1.Fetch(context.Background(), testSrv.URL+"?token=t1")
+	require.NoError(t, err)
+	_, err = s2.Fetch(context.Background(), testSrv.URL+"?token=t2")
+	require.NoError(t, err)
+
+	// cookies are kept in each session
+	content, err := s1.FetchRequest(context.Background(), post)
+	require.NoError(t, err)
+	assert.Equal(t, "t1:q=abc.com", string(content))
+	content, err = s2.FetchRequest(context.Background(), post)
+	require.NoError(t, err)
+	assert.Equal(t, "t2:q=abc.com", string(content))
+
+	// finder without session does not keep cookies
+	_, err = sdf.FetchRequest(context.Background(), post)
+	assert.Error(t, err)
+	assert.Nil(t, sdf.Client.Jar)
+}
@@ -1,11 +1,15 @@
 package base
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"strings"
 	"sync/atomic"
@@ -23,28 +27,44 @@ const (
 	RLPSubdomain     = "subdomain"
 	RLPRvsDNS        = "reverse"
 
-	FromCrawl = "crawl"
-	FromAPI   = "api"
-	FromCert  = "cert"
+	FromCrawl   = "crawl"
+	FromAPI     = "api"
+	FromCert    = "cert"
+	FromArchive = "archive"
+	FromActive  = "active" // query dns servers of the domain instead of third party sources
+	FromFile    = "file"   // read local dumps instead of querying remote sources
 )
 
+// ErrCanceled is returned when the query is stopped because the context is canceled
+// or passed its deadline, which is recorded separately from errors of the sources
+var ErrCanceled = errors.New("query canceled")
+
 type InputType int
 
 const (
 	InputDomain InputType = iota
 	InputIP
+	InputCIDR
 )
 
 // SDFinderMap stores all available sources, while 'SubdomainFinder.Init(opts...)' is needed
 // to actually let SubdomainFinder ready for work
 var SDFinderMap = make(map[string]SubdomainFinder)
 
-// MustRegister registers subdomain finder to 'SDFinderMap'
-func MustRegister(sdname string, sdfinder SubdomainFinder) {
+// Register registers subdomain finder to 'SDFinderMap', it fails if the name has been registered
+func Register(sdname string, sdfinder SubdomainFinder) error {
 	if _, exist := SDFinderMap[sdname]; exist {
-		panic(sdname + "has been registered")
+		return fmt.Errorf("%s has been registered", sdname)
 	}
 	SDFinderMap[sdname] = sdfinder
+	return nil
+}
+
+// MustRegister registers subdomain finder to 'SDFinderMap', it panics if the name has been registered
+func MustRegister(sdname string, sdfinder SubdomainFinder) {
+	if err := Register(sdname, sdfinder); err != nil {
+		panic(err)
+	}
 }
 
 type SubdomainFinder interface {
@@ -58,27 +78,87 @@ type SubdomainFinder interface {
 	Workers() int
 }
 
+// ExInfo is extra information of found domains from source, E.g., resolved ip and first seen time,
+// which is keyed by domain and put into 'extra_info' of output
+type ExInfo map[string]map[string]string
+
+// Set sets the information of domain, domain is converted to lowercase as Uniq does
+func (ei ExInfo) Set(domain, key, val string) {
+	domain = strings.ToLower(domain)
+	if _, exist := ei[domain]; !exist {
+		ei[domain] = make(map[string]string)
+	}
+	ei[domain][key] = val
+}
+
+// Get returns the information of domain, empty string if it's not given
+func (ei ExInfo) Get(domain, key string) string {
+	return ei[strings.ToLower(domain)][key]
+}
+
+// InfoFinder is implemented by sources that provide extra information of found domains
+type InfoFinder interface {
+	GetWithInfo(context.Context, string) ([]string, ExInfo, error)
+}
+
 type SDFinder struct {
-	RLimiter        *rate.Limiter
-	Client          *http.Client
-	Header          *http.Header
-	URLbuilder      func(string) string // build different url base on input domain
-	Parse           func([]byte) ([]string, error)
-	TimeAfter       time.Time
-	Stat            *Stat
-	RetriesTimes    int
-	RetriesInterval time.Duration
-	Worker          int
+	RLimiter       *rate.Limiter
+	Adaptive       *Adaptive // adjust rate of RLimiter base on feedback of source, nil if disabled
+	Client         *http.Client
+	Header         *http.Header
+	Method         string              // http method of requests, GET if not given
+	Keys           *KeyRing            // api keys rotated for each request, nil if source does not need it
+	Quota          *Quota              // limit calls per day or month, nil if no limit
+	Proxies        *ProxyPool          // send requests through proxies, nil if not using proxy
+	Profiles       *HeaderProfiles     // rotate browser-like headers, nil if not using profiles
+	URLbuilder     func(string) string // build different url base on input domain
+	Parse          func([]byte) ([]string, error)
+	ParseStream    func(io.Reader) ([]string, error)      // parse body while reading instead of Parse, for huge responses
+	PageURLbuilder func(string, Page) string              // build url of given page in paginated fetch mode
+	ParsePage      func([]byte) ([]string, string, error) // enable paginated fetch mode, return cursor of next page
+	MaxPages       int                                    // max pages to fetch for each domain, no limit if 0
+	MaxRespBytes   int64                                  // max size of response body, no limit if 0
+	TruncateResp   bool                                   // truncate body exceeding MaxRespBytes instead of aborting
+	TimeAfter      time.Time
+	Params         map[string]string // source specific parameters, E.g., amount of collections to query
+	Stat           *Stat
+	Retry          RetryPolicy
+	Worker         int
+}
+
+// Page locates the page to fetch in paginated fetch mode
+type Page struct {
+	Num    int    // page number, starts from 1
+	Cursor string // cursor, offset or next url parsed from previous page, empty for the first page
 }
 
 type Stat struct {
-	DomainsCnt       uint64 `json:"domain"` // total unique domains
-	SuccessCnt       uint64 `json:"success,omitempty"`
-	FoundCnt         uint64 `json:"found,omitempty"`
-	NotFoundCnt      uint64 `json:"notfound,omitempty"`
-	TimeoutCnt       uint64 `json:"timeout,omitempty"`
-	ErrCnt           uint64 `json:"error,omitempty"`
-	RelatedDomainCnt uint64 `json:"related"` // total related domain count (filter duplicate)
+	DomainsCnt       uint64            `json:"domain"` // total unique domains
+	SuccessCnt       uint64            `json:"success,omitempty"`
+	FoundCnt         uint64            `json:"found,omitempty"`
+	NotFoundCnt      uint64            `json:"notfound,omitempty"`
+	TimeoutCnt       uint64            `json:"timeout,omitempty"`
+	ErrCnt           uint64            `json:"error,omitempty"`
+	CanceledCnt      uint64            `json:"canceled,omitempty"`
+	QuotaSkipCnt     uint64            `json:"quota_skip,omitempty"`  // skipped since quota is exhausted
+	TooLargeCnt      uint64            `json:"too_large,omitempty"`   // aborted since response exceeds max response bytes
+	TruncatedCnt     uint64            `json:"truncated,omitempty"`   // responses truncated to max response bytes
+	RelatedDomainCnt uint64            `json:"related"`               // total related domain count (filter duplicate)
+	PageCnt          uint64            `json:"page,omitempty"`        // total pages fetched in paginated fetch mode
+	PageLimitCnt     uint64            `json:"page_limit,omitempty"`  // domains that stop fetching because of max pages
+	RetryCnt         uint64            `json:"retry,omitempty"`       // total retried requests
+	ThrottleCnt      uint64            `json:"throttle,omitempty"`    // total requests throttled by source
+	QPS              float64           `json:"qps,omitempty"`         // effective qps at the end if adaptive rate limiting is enabled
+	ProxyErr         map[string]uint64 `json:"proxy_error,omitempty"` // error count of each proxy
+	ReportedCnt      uint64            `json:"reported,omitempty"`    // total subdomain count reported by source
+	IncompleteCnt    uint64            `json:"incomplete,omitempty"`  // domains that source returns less than it reports
+	LimitSkipCnt     uint64            `json:"limit_skip,omitempty"`  // items skipped by limit of source, E.g., ctlog entries beyond max entries
+
+	// statistic of active sources
+	ZoneTransferCnt    uint64 `json:"zone_transfer,omitempty"`          // domains whose nameserver allows zone transfer
+	TransferRefusedCnt uint64 `json:"transfer_refused,omitempty"`       // nameserver addresses refusing or failing zone transfer
+	NSUnreachableCnt   uint64 `json:"nameserver_unreachable,omitempty"` // nameserver addresses not reachable
+	LookupErrCnt       uint64 `json:"lookup_error,omitempty"`           // lookups failing after retries, which are skipped
 }
 
 type Option func(*SDFinder) error
@@ -88,6 +168,7 @@ func NewSDFinder() *SDFinder {
 		RLimiter: rate.NewLimiter(DefaultQPS, 1),
 		Client:   &http.Client{Timeout: DefaultTimeout},
 		Stat:     new(Stat),
+		Retry:    DefaultRetryPolicy(),
 		Worker:   DefaultWorker,
 	}
 }
@@ -98,6 +179,15 @@ func (sdf *SDFinder) Init(opts ...Option) error {
 			return err
 		}
 	}
+	if sdf.Keys != nil && sdf.Keys.Len() > 0 && len(sdf.Keys.Name) == 0 {
+		return fmt.Errorf("api key name should be given")
+	}
+	if sdf.Adaptive != nil {
+		sdf.Adaptive.bind(sdf.RLimiter)
+		if sdf.Adaptive.Min > sdf.Adaptive.Max {
+			return fmt.Errorf("adaptive min qps should <= max qps")
+		}
+	}
 	return nil
 }
 
@@ -131,6 +221,18 @@ func Header(key, val string) Option {
 	}
 }
 
+func Method(method string) Option {
+	return func(sdf *SDFinder) error {
+		switch method {
+		case http.MethodGet, http.MethodPost:
+		default:
+			return fmt.Errorf("method should be %s or %s", http.MethodGet, http.MethodPost)
+		}
+		sdf.Method = method
+		return nil
+	}
+}
+
 func TimeAfter(ta time.Time) Option {
 	return func(sdf *SDFinder) error {
 		sdf.TimeAfter = ta
@@ -158,16 +260,36 @@ func Parse(f func([]byte) ([]string, error)) Option {
 	}
 }
 
-func Retries(retries int, interval time.Duration) Option {
+// PageUrlBuilder sets the url builder for paginated fetch mode. If it's not given,
+// URLbuilder is used for the first page and the cursor is used as url of next page
+func PageUrlBuilder(f func(string, Page) string) Option {
 	return func(sdf *SDFinder) error {
-		if retries < 0 {
-			return fmt.Errorf("retries should >= 0")
+		if f == nil {
+			return fmt.Errorf("empty page url builder function")
 		}
-		if retries > 0 && interval <= 0 {
-			return fmt.Errorf("retries interval should >= 0 when retries > 0")
+		sdf.PageURLbuilder = f
+		return nil
+	}
+}
+
+// ParsePage enables paginated fetch mode, the parse function returns subdomains
+// and the cursor of next page, empty cursor means there is no more page
+func ParsePage(f func([]byte) ([]string, string, error)) Option {
+	return func(sdf *SDFinder) error {
+		if f == nil {
+			return fmt.Errorf("empty parse page function")
 		}
-		sdf.RetriesTimes = retries
-		sdf.RetriesInterval = interval
+		sdf.ParsePage = f
+		return nil
+	}
+}
+
+func MaxPages(pages int) Option {
+	return func(sdf *SDFinder) error {
+		if pages < 0 {
+			return fmt.Errorf("max pages should >= 0")
+		}
+		sdf.MaxPages = pages
 		return nil
 	}
 }
@@ -199,9 +321,23 @@ func (sdf SDFinder) RelatedType() string {
 }
 
 func (sdf SDFinder) GetStat() *Stat {
+	if sdf.Adaptive != nil {
+		sdf.Stat.QPS = sdf.Adaptive.Limit()
+	}
+	if sdf.Proxies != nil {
+		sdf.Stat.ProxyErr = sdf.Proxies.ErrCounts()
+	}
 	return sdf.Stat
 }
 
+// throttled records the request is throttled by source, and decreases the rate if adaptive is enabled
+func (sdf *SDFinder) throttled() {
+	atomic.AddUint64(&sdf.Stat.ThrottleCnt, uint64(1))
+	if sdf.Adaptive != nil {
+		sdf.Adaptive.OnThrottle()
+	}
+}
+
 func (sdf SDFinder) Workers() int {
 	return sdf.Worker
 }
@@ -214,10 +350,37 @@ func IsTimeout(err error) bool {
 	return false
 }
 
+// CtxErr wraps err with ErrCanceled if the context is done, so that the query stopped
+// by cancellation is not classified as error or timeout of the source
+func CtxErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ErrCanceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrCanceled, err)
+}
+
+// Sleep pauses for duration d, it returns early with context error if ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (sdf *SDFinder) RecordStat(subdomains []string, err error) {
 	atomic.AddUint64(&sdf.Stat.DomainsCnt, uint64(1))
 	if err != nil {
-		if IsTimeout(err) {
+		if errors.Is(err, ErrCanceled) {
+			atomic.AddUint64(&sdf.Stat.CanceledCnt, uint64(1))
+		} else if errors.Is(err, ErrQuotaExhausted) {
+			atomic.AddUint64(&sdf.Stat.QuotaSkipCnt, uint64(1))
+		} else if errors.Is(err, ErrResponseTooLarge) {
+			atomic.AddUint64(&sdf.Stat.TooLargeCnt, uint64(1))
+		} else if IsTimeout(err) {
 			atomic.AddUint64(&sdf.Stat.TimeoutCnt, uint64(1))
 		} else {
 			atomic.AddUint64(&sdf.Stat.ErrCnt, uint64(1))
@@ -233,61 +396,281 @@ func (sdf *SDFinder) RecordStat(subdomains []string, err error) {
 	}
 }
 
-func (sdf *SDFinder) Do(ctx context.Context, url string) ([]byte, error) {
-	err := sdf.RLimiter.Wait(ctx)
-	if err != nil {
+// RecordReported records the total count reported by source along with the amount actually returned,
+// the domain is counted as incomplete if the source truncates its results
+func (sdf *SDFinder) RecordReported(reported, returned int) {
+	atomic.AddUint64(&sdf.Stat.ReportedCnt, uint64(reported))
+	if returned < reported {
+		atomic.AddUint64(&sdf.Stat.IncompleteCnt, uint64(1))
+	}
+}
+
+// RecordLimitSkip records items skipped by limit of source, the count stays at max uint64 instead of
+// overflowing since the skipped addresses of ipv6 cidr are huge
+func (sdf *SDFinder) RecordLimitSkip(skipped uint64) {
+	for {
+		cnt := atomic.LoadUint64(&sdf.Stat.LimitSkipCnt)
+		sum := cnt + skipped
+		if sum < cnt {
+			sum = math.MaxUint64
+		}
+		if atomic.CompareAndSwapUint64(&sdf.Stat.LimitSkipCnt, cnt, sum) {
+			return
+		}
+	}
+}
+
+// open sends request and returns the response with status code 200, the body should be closed by caller.
+// The request is sent once again with the next key if the key is rejected
+func (sdf *SDFinder) open(ctx context.Context, r Request) (*http.Response, error) {
+	rsp, err := sdf.send(ctx, r)
+	var serr *StatusError
+	if errors.As(err, &serr) && (serr.Code == http.StatusUnauthorized || serr.Code == http.StatusForbidden) &&
+		sdf.Keys != nil && sdf.Keys.Available() {
+		return sdf.send(ctx, r)
+	}
+	return rsp, err
+}
+
+func (sdf *SDFinder) send(ctx context.Context, r Request) (*http.Response, error) {
+	// skip without waiting for rate limiter if quota has been spent
+	if sdf.Quota != nil && sdf.Quota.Exhausted() {
+		return nil, ErrQuotaExhausted
+	}
+	// wait for the key before rate limiter if all the keys are rate limited
+	var key string
+	var err error
+	if sdf.Keys != nil && sdf.Keys.Len() > 0 {
+		if key, err = sdf.Keys.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err = sdf.RLimiter.Wait(ctx); err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if sdf.Quota != nil {
+		if err := sdf.Quota.Take(); err != nil {
+			return nil, err
+		}
+	}
+	reqCtx := ctx
+	var proxy *neturl.URL
+	if sdf.Proxies != nil {
+		reqCtx, proxy = sdf.Proxies.withProxy(ctx)
+	}
+	method := r.Method
+	if len(method) == 0 {
+		method = sdf.Method
+	}
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if r.Body != nil {
+		reqBody = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(reqCtx, method, r.URL, reqBody)
 	if err != nil {
 		return nil, err
 	}
 	if sdf.Header != nil {
-		req.Header = *sdf.Header
+		req.Header = sdf.Header.Clone()
+	}
+	for key, vals := range r.Header {
+		req.Header[key] = vals
+	}
+	if sdf.Profiles != nil {
+		sdf.Profiles.apply(ctx, req)
+	}
+	if len(key) > 0 {
+		sdf.Keys.Apply(req, key)
 	}
 	rsp, err := sdf.Client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			if IsTimeout(err) {
+				sdf.throttled()
+			}
+			if proxy != nil {
+				sdf.Proxies.RecordErr(proxy)
+			}
+		}
+		return nil, RedactErr(err)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		if proxy != nil && rsp.StatusCode == http.StatusProxyAuthRequired {
+			sdf.Proxies.RecordErr(proxy)
+		}
+		if len(key) > 0 {
+			sdf.Keys.Demote(key, rsp.StatusCode)
+		}
+		if rsp.StatusCode == http.StatusTooManyRequests {
+			sdf.throttled()
+		}
+		return nil, newStatusError(rsp)
+	}
+	return rsp, nil
+}
+
+func (sdf *SDFinder) Do(ctx context.Context, url string) ([]byte, error) {
+	return sdf.DoWithBody(ctx, url, nil)
+}
+
+// DoWithBody sends request with body, which is used by sources querying with POST
+func (sdf *SDFinder) DoWithBody(ctx context.Context, url string, body []byte) ([]byte, error) {
+	return sdf.DoRequest(ctx, Request{URL: url, Body: body})
+}
+
+// DoRequest sends request with its own method and headers
+func (sdf *SDFinder) DoRequest(ctx context.Context, r Request) ([]byte, error) {
+	rsp, err := sdf.open(ctx, r)
 	if err != nil {
 		return nil, err
 	}
 	defer rsp.Body.Close()
-	if rsp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get rsp code: %d", rsp.StatusCode)
+	capped, err := sdf.capBody(rsp)
+	if err != nil {
+		return nil, err
 	}
-	content, err := io.ReadAll(rsp.Body)
+	content, err := io.ReadAll(capped)
 	if err != nil {
 		return nil, err
 	}
+	sdf.recordTruncated(capped)
+	if sdf.Adaptive != nil {
+		if sdf.Adaptive.IsSlowDown(content) {
+			sdf.throttled()
+			return nil, ErrSlowDown
+		}
+		sdf.Adaptive.OnSuccess()
+	}
 	return content, nil
 }
 
+// RetryDo calls do until it succeeds, and retries base on retry policy if the error is retryable.
+// It's also used by sources not querying with http, E.g., dns lookups
+func (sdf *SDFinder) RetryDo(ctx context.Context, do func() error) error {
+	start := time.Now()
+	for n := 1; ; n++ {
+		err := do()
+		if err == nil || n > sdf.Retry.Times || ctx.Err() != nil || !sdf.Retry.Retryable(err) {
+			return err
+		}
+		wait := sdf.Retry.Backoff(n, err)
+		if sdf.Retry.MaxElapsed > 0 && time.Since(start)+wait > sdf.Retry.MaxElapsed {
+			return err
+		}
+		atomic.AddUint64(&sdf.Stat.RetryCnt, uint64(1))
+		if err := Sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Fetch requests given url, and retries base on retry policy if the error is retryable
+func (sdf *SDFinder) Fetch(ctx context.Context, url string) (content []byte, err error) {
+	return sdf.FetchWithBody(ctx, url, nil)
+}
+
+// FetchWithBody is Fetch with request body
+func (sdf *SDFinder) FetchWithBody(ctx context.Context, url string, body []byte) (content []byte, err error) {
+	return sdf.FetchRequest(ctx, Request{URL: url, Body: body})
+}
+
+// FetchRequest is Fetch with its own method and headers
+func (sdf *SDFinder) FetchRequest(ctx context.Context, r Request) (content []byte, err error) {
+	err = sdf.RetryDo(ctx, func() error {
+		content, err = sdf.DoRequest(ctx, r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (sdf *SDFinder) pageURL(domain string, page Page) string {
+	if sdf.PageURLbuilder != nil {
+		return sdf.PageURLbuilder(domain, page)
+	}
+	if page.Num == 1 {
+		return sdf.URLbuilder(domain)
+	}
+	return page.Cursor
+}
+
+// GetPages fetches pages one by one in paginated fetch mode until there is no more page
+// or MaxPages is reached. If any page fails, subdomains of fetched pages are returned with error,
+// while Get drops them and treats the query as failed
+func (sdf *SDFinder) GetPages(ctx context.Context, domain string) (subdomains []string, err error) {
+	page := Page{Num: 1}
+	seen := map[string]struct{}{page.Cursor: {}}
+	for {
+		if sdf.MaxPages > 0 && page.Num > sdf.MaxPages {
+			atomic.AddUint64(&sdf.Stat.PageLimitCnt, uint64(1))
+			return subdomains, nil
+		}
+		content, err := sdf.Fetch(ctx, sdf.pageURL(domain, page))
+		if err != nil {
+			return subdomains, err
+		}
+		atomic.AddUint64(&sdf.Stat.PageCnt, uint64(1))
+		sbs, next, err := sdf.ParsePage(content)
+		if err != nil {
+			return subdomains, err
+		}
+		subdomains = append(subdomains, sbs...)
+		// stop if there is no more page, or cursor has been seen which might loop forever
+		if _, exist := seen[next]; len(next) == 0 || exist {
+			return subdomains, nil
+		}
+		seen[next] = struct{}{}
+		page = Page{Num: page.Num + 1, Cursor: next}
+	}
+}
+
+// Uniq converts subdomains to lowercase and deduplicates them
+func Uniq(subdomains []string) []string {
+	var result []string
+	uniDomainMap := make(map[string]struct{})
+	for _, sb := range subdomains {
+		sblower := strings.ToLower(sb)
+		if _, hasseen := uniDomainMap[sblower]; hasseen {
+			continue
+		}
+		uniDomainMap[sblower] = struct{}{}
+		result = append(result, sblower)
+	}
+	return result
+}
+
 func (sdf *SDFinder) Get(ctx context.Context, domain string) (subdomains []string, err error) {
 	defer func() {
+		err = CtxErr(ctx, err)
 		sdf.RecordStat(subdomains, err)
 	}()
-	uniDomainMap := make(map[string]struct{})
-	retryTimes := sdf.RetriesTimes
-	for retryTimes >= 0 {
-		retryTimes--
-		content, err := sdf.Do(ctx, sdf.URLbuilder(domain))
+	if sdf.ParsePage != nil {
+		sbs, err := sdf.GetPages(ctx, domain)
 		if err != nil {
-			if retryTimes < 0 {
-				return subdomains, err // retry n times and failed
-			}
-			time.Sleep(sdf.RetriesInterval)
-			continue
+			return nil, err
 		}
-		sbs, err := sdf.Parse(content)
+		return Uniq(sbs), nil
+	}
+	if sdf.ParseStream != nil {
+		sbs, err := sdf.FetchStream(ctx, sdf.URLbuilder(domain), sdf.ParseStream)
 		if err != nil {
-			return subdomains, err // no need to retry
-		}
-		// convert to lowercase and deduplicate using map
-		for _, sb := range sbs {
-			sblower := strings.ToLower(sb)
-			uniDomainMap[sblower] = struct{}{}
+			return nil, err
 		}
+		return Uniq(sbs), nil
 	}
-	for sb := range uniDomainMap {
-		subdomains = append(subdomains, sb)
+	content, err := sdf.Fetch(ctx, sdf.URLbuilder(domain))
+	if err != nil {
+		return nil, err
+	}
+	sbs, err := sdf.Parse(content)
+	if err != nil {
+		return nil, err
 	}
-	return subdomains, nil
+	return Uniq(sbs), nil
 }
@@ -0,0 +1,189 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSDFinder(t *testing.T, url string, opts ...Option) *SDFinder {
+	sdf := NewSDFinder()
+	opts = append([]Option{
+		UrlBuilder(func(domain string) string { return url + "/?q=" + domain }),
+		Parse(func(content []byte) ([]string, error) {
+			return strings.Split(string(content), "\n"), nil
+		}),
+	}, opts...)
+	require.NoError(t, sdf.Init(opts...))
+	return sdf
+}
+
+func TestGetCanceled(t *testing.T) {
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+		w.Write([]byte("abc.abc.com"))
+	}))
+	defer testSrv.Close()
+
+	sdf := newTestSDFinder(t, testSrv.URL, Timeout(5*time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	subdomains, err := sdf.Get(ctx, "abc.com")
+	assert.ErrorIs(t, err, ErrCanceled)
+	assert.Empty(t, subdomains)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, uint64(1), sdf.Stat.DomainsCnt)
+	assert.Equal(t, uint64(1), sdf.Stat.CanceledCnt)
+	assert.Equal(t, uint64(0), sdf.Stat.TimeoutCnt)
+	assert.Equal(t, uint64(0), sdf.Stat.ErrCnt)
+}
+
+func TestGetCanceledWhileRetrying(t *testing.T) {
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer testSrv.Close()
+
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), Retries(3, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := sdf.Get(ctx, "abc.com")
+	assert.ErrorIs(t, err, ErrCanceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, uint64(1), sdf.Stat.CanceledCnt)
+}
+
+// newPagedServer serves 'sub<n>.abc.com' in each page with cursor of next page in the last line
+func newPagedServer(pages int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		num, _ := strconv.Atoi(req.URL.Query().Get("cursor"))
+		w.Write([]byte("sub" + strconv.Itoa(num) + ".abc.com\nSUB" + strconv.Itoa(num) + ".abc.com\n"))
+		if num+1 < pages {
+			w.Write([]byte(strconv.Itoa(num + 1)))
+		}
+	}))
+}
+
+func parseTestPage(content []byte) ([]string, string, error) {
+	lines := strings.Split(string(content), "\n")
+	return lines[:len(lines)-1], lines[len(lines)-1], nil
+}
+
+func TestGetPages(t *testing.T) {
+	testSrv := newPagedServer(3)
+	defer testSrv.Close()
+
+	sdf := NewSDFinder()
+	require.NoError(t, sdf.Init(
+		QPS(100),
+		PageUrlBuilder(func(domain string, page Page) string {
+			return testSrv.URL + "/?q=" + domain + "&cursor=" + page.Cursor
+		}),
+		ParsePage(parseTestPage),
+	))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	assert.Equal(t, []string{"sub0.abc.com", "sub1.abc.com", "sub2.abc.com"}, subdomains)
+	assert.Equal(t, uint64(1), sdf.Stat.SuccessCnt)
+	assert.Equal(t, uint64(3), sdf.Stat.RelatedDomainCnt)
+	assert.Equal(t, uint64(3), sdf.Stat.PageCnt)
+	assert.Equal(t, uint64(0), sdf.Stat.PageLimitCnt)
+
+	// stop when max pages is reached
+	sdf = NewSDFinder()
+	require.NoError(t, sdf.Init(
+		QPS(100),
+		PageUrlBuilder(func(domain string, page Page) string {
+			return testSrv.URL + "/?q=" + domain + "&cursor=" + page.Cursor
+		}),
+		ParsePage(parseTestPage),
+		MaxPages(2),
+	))
+	subdomains, err = sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	assert.Equal(t, []string{"sub0.abc.com", "sub1.abc.com"}, subdomains)
+	assert.Equal(t, uint64(2), sdf.Stat.PageCnt)
+	assert.Equal(t, uint64(1), sdf.Stat.PageLimitCnt)
+}
+
+func TestGetPagesNextURL(t *testing.T) {
+	var testSrv *httptest.Server
+	testSrv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/next" {
+			w.Write([]byte("b.abc.com\n"))
+			return
+		}
+		w.Write([]byte("a.abc.com\n" + testSrv.URL + "/next"))
+	}))
+	defer testSrv.Close()
+
+	// cursor is used as url of next page if page url builder is not given
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), ParsePage(parseTestPage))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+	assert.Equal(t, uint64(2), sdf.Stat.PageCnt)
+}
+
+func TestGetPagesCursorCycle(t *testing.T) {
+	// cursor goes '' -> 'a' -> 'b' -> 'a'
+	next := map[string]string{"": "a", "a": "b", "b": "a"}
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cursor := req.URL.Query().Get("cursor")
+		w.Write([]byte("sub" + cursor + ".abc.com\n" + next[cursor]))
+	}))
+	defer testSrv.Close()
+
+	sdf := NewSDFinder()
+	require.NoError(t, sdf.Init(
+		QPS(100),
+		PageUrlBuilder(func(domain string, page Page) string {
+			return testSrv.URL + "/?q=" + domain + "&cursor=" + page.Cursor
+		}),
+		ParsePage(parseTestPage),
+	))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	assert.Equal(t, []string{"sub.abc.com", "suba.abc.com", "subb.abc.com"}, subdomains)
+	assert.Equal(t, uint64(3), sdf.Stat.PageCnt)
+}
+
+func TestGetPagesFail(t *testing.T) {
+	var testSrv *httptest.Server
+	testSrv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/next" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("a.abc.com\n" + testSrv.URL + "/next"))
+	}))
+	defer testSrv.Close()
+
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), ParsePage(parseTestPage))
+	// subdomains of fetched pages are returned with error
+	subdomains, err := sdf.GetPages(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"a.abc.com"}, subdomains)
+	// query fails in Get
+	subdomains, err = sdf.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, uint64(1), sdf.Stat.ErrCnt)
+}
@@ -0,0 +1,133 @@
+package base
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// slowDownPeek is the size of the beginning of body checked for slow down patterns in streaming mode
+const slowDownPeek = 4096
+
+// ErrResponseTooLarge is returned when the response body exceeds max response bytes of source
+var ErrResponseTooLarge = errors.New("response too large")
+
+// cappedReader reads at most limit bytes from r, it returns ErrResponseTooLarge if there is
+// more content, or io.EOF if truncate is set. No limit if limit is 0
+type cappedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+	truncate  bool
+	truncated bool
+}
+
+func (cr *cappedReader) Read(p []byte) (int, error) {
+	if cr.limit <= 0 {
+		return cr.r.Read(p)
+	}
+	if cr.remaining <= 0 {
+		// check whether the body ends exactly at the limit
+		var probe [1]byte
+		if n, err := io.ReadFull(cr.r, probe[:]); n == 0 {
+			return 0, err
+		}
+		if cr.truncate {
+			cr.truncated = true
+			return 0, io.EOF
+		}
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.remaining -= int64(n)
+	return n, err
+}
+
+// capBody limits the size of response body, it fails early if Content-Length already exceeds the limit
+func (sdf *SDFinder) capBody(rsp *http.Response) (*cappedReader, error) {
+	if sdf.MaxRespBytes > 0 && !sdf.TruncateResp && rsp.ContentLength > sdf.MaxRespBytes {
+		return nil, ErrResponseTooLarge
+	}
+	return &cappedReader{r: rsp.Body, limit: sdf.MaxRespBytes, remaining: sdf.MaxRespBytes, truncate: sdf.TruncateResp}, nil
+}
+
+func (sdf *SDFinder) recordTruncated(body *cappedReader) {
+	if body.truncated {
+		atomic.AddUint64(&sdf.Stat.TruncatedCnt, uint64(1))
+	}
+}
+
+// DoStream requests given url and parses the body while reading, so that the whole body is never held in memory
+func (sdf *SDFinder) DoStream(ctx context.Context, url string, parse func(io.Reader) ([]string, error)) ([]string, error) {
+	rsp, err := sdf.open(ctx, Request{URL: url})
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := sdf.capBody(rsp)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(body, slowDownPeek)
+	if sdf.Adaptive != nil {
+		// error is returned again when parser reads the body, only the content is checked here
+		head, _ := br.Peek(slowDownPeek)
+		if sdf.Adaptive.IsSlowDown(head) {
+			sdf.throttled()
+			return nil, ErrSlowDown
+		}
+	}
+	subdomains, err := parse(br)
+	if body.truncated {
+		// parser might fail at where the body is cut, keep the subdomains parsed before it
+		sdf.recordTruncated(body)
+		err = nil
+	}
+	if err != nil {
+		return subdomains, err
+	}
+	if sdf.Adaptive != nil {
+		sdf.Adaptive.OnSuccess()
+	}
+	return subdomains, nil
+}
+
+// FetchStream is the streaming version of Fetch, subdomains parsed before error are returned along with the error
+func (sdf *SDFinder) FetchStream(ctx context.Context, url string, parse func(io.Reader) ([]string, error)) (subdomains []string, err error) {
+	err = sdf.RetryDo(ctx, func() error {
+		subdomains, err = sdf.DoStream(ctx, url, parse)
+		return err
+	})
+	return subdomains, err
+}
+
+// ParseStream sets the parse function that consumes body while reading instead of Parse
+func ParseStream(f func(io.Reader) ([]string, error)) Option {
+	return func(sdf *SDFinder) error {
+		if f == nil {
+			return fmt.Errorf("empty parse stream function")
+		}
+		sdf.ParseStream = f
+		return nil
+	}
+}
+
+// MaxResponseBytes limits the size of response body, the request is aborted with ErrResponseTooLarge
+// if the body exceeds the limit, or the body is truncated to the limit if truncate is set
+func MaxResponseBytes(limit int64, truncate bool) Option {
+	return func(sdf *SDFinder) error {
+		if limit < 0 {
+			return fmt.Errorf("max response bytes should >= 0")
+		}
+		sdf.MaxRespBytes = limit
+		sdf.TruncateResp = truncate
+		return nil
+	}
+}
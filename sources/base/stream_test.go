@@ -0,0 +1,106 @@
+package base
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestStream(r io.Reader) ([]string, error) {
+	var subdomains []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		subdomains = append(subdomains, scanner.Text())
+	}
+	return subdomains, scanner.Err()
+}
+
+func TestCappedReader(t *testing.T) {
+	for _, tc := range []struct {
+		content  string
+		limit    int64
+		truncate bool
+		exp      string
+		err      error
+	}{
+		{content: "abcdef", limit: 0, exp: "abcdef"},
+		{content: "abcdef", limit: 6, exp: "abcdef"},
+		{content: "abcdef", limit: 10, exp: "abcdef"},
+		{content: "abcdef", limit: 3, err: ErrResponseTooLarge},
+		{content: "abcdef", limit: 3, truncate: true, exp: "abc"},
+	} {
+		cr := &cappedReader{r: strings.NewReader(tc.content), limit: tc.limit, remaining: tc.limit, truncate: tc.truncate}
+		content, err := io.ReadAll(cr)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.exp, string(content))
+		assert.Equal(t, tc.exp != tc.content, cr.truncated)
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	content := "a.abc.com\nb.abc.com\nc.abc.com\nd.abc.com"
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("chunked") == "" {
+			w.Header().Set("Content-Length", "39")
+		}
+		w.Write([]byte(content))
+	}))
+	defer testSrv.Close()
+
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), ParseStream(parseTestStream))
+	subdomains, err := sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com", "c.abc.com", "d.abc.com"}, subdomains)
+
+	// aborted by Content-Length or while reading chunked body
+	for _, domain := range []string{"abc.com", "abc.com&chunked=1"} {
+		sdf = newTestSDFinder(t, testSrv.URL, QPS(100), ParseStream(parseTestStream), MaxResponseBytes(15, false))
+		subdomains, err = sdf.Get(context.Background(), domain)
+		assert.ErrorIs(t, err, ErrResponseTooLarge)
+		assert.Empty(t, subdomains)
+		assert.Equal(t, uint64(1), sdf.Stat.TooLargeCnt)
+		assert.Equal(t, uint64(0), sdf.Stat.ErrCnt)
+	}
+
+	// subdomains before the limit are kept when truncated
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), ParseStream(parseTestStream), MaxResponseBytes(15, true))
+	subdomains, err = sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.abc.com", "b.abc"}, subdomains)
+	assert.Equal(t, uint64(1), sdf.Stat.TruncatedCnt)
+	assert.Equal(t, uint64(1), sdf.Stat.SuccessCnt)
+
+	// not streaming
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), MaxResponseBytes(15, false))
+	_, err = sdf.Get(context.Background(), "abc.com&chunked=1")
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+	assert.Equal(t, uint64(1), sdf.Stat.TooLargeCnt)
+	sdf = newTestSDFinder(t, testSrv.URL, QPS(100), MaxResponseBytes(19, true))
+	subdomains, err = sdf.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+	assert.Equal(t, uint64(1), sdf.Stat.TruncatedCnt)
+}
+
+func TestGetStreamSlowDown(t *testing.T) {
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("API count exceeded"))
+	}))
+	defer testSrv.Close()
+
+	sdf := newTestSDFinder(t, testSrv.URL, QPS(100), ParseStream(parseTestStream), SlowDown("API count exceeded"))
+	_, err := sdf.Get(context.Background(), "abc.com")
+	assert.ErrorIs(t, err, ErrSlowDown)
+	assert.Equal(t, uint64(1), sdf.Stat.ThrottleCnt)
+}
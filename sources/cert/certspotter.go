@@ -0,0 +1,92 @@
+package cert
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://api.certspotter.com/v1/issuances?domain=<domain>&include_subdomains=true&expand=dns_names&after=<id>
+// issuances are sorted by id, fetch next page with the id of the last issuance in 'after' parameter
+// until an empty list is returned. api key is optional and sent in 'Authorization: Bearer <key>' header
+/*[
+    {
+        "id": "3965430390",
+        "tbs_sha256": "...",
+        "cert_sha256": "...",
+        "dns_names": ["bench.com", "www.bench.com"],
+        "pubkey_sha256": "...",
+        "not_before": "2022-05-10T00:00:00Z",
+        "not_after": "2023-05-10T23:59:59Z",
+        "revoked": false
+    }
+]*/
+const NameCertspotter = "certspotter"
+
+func init() {
+	base.MustRegister(NameCertspotter, NewCertspotter())
+}
+
+type Certspotter struct{ base.SDFinder }
+type CertspotterRsp struct {
+	ID       string    `json:"id"`
+	DNSNames []string  `json:"dns_names"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+func NewCertspotter() *Certspotter {
+	return &Certspotter{*base.NewSDFinder()}
+}
+
+func (c *Certspotter) Init(opts ...base.Option) error {
+	c.URLbuilder = func(domain string) string {
+		return "https://api.certspotter.com/v1/issuances?domain=" + url.QueryEscape(domain) +
+			"&include_subdomains=true&expand=dns_names"
+	}
+	c.PageURLbuilder = func(domain string, page base.Page) string {
+		if len(page.Cursor) == 0 {
+			return c.URLbuilder(domain)
+		}
+		return c.URLbuilder(domain) + "&after=" + url.QueryEscape(page.Cursor)
+	}
+	c.ParsePage = c.parsePage
+	// api key is optional, which raises the rate limit
+	opts = append([]base.Option{base.KeyPlacement(base.KeyInHeader, "Authorization", "Bearer ")}, opts...)
+	return c.SDFinder.Init(opts...)
+}
+
+// parsePage returns dns names of issuances in the page and the id of last issuance as cursor,
+// expired issuances are skipped if TimeAfter is given
+func (c *Certspotter) parsePage(content []byte) ([]string, string, error) {
+	var issuances []CertspotterRsp
+	if err := json.Unmarshal(content, &issuances); err != nil {
+		return nil, "", err
+	}
+	if len(issuances) == 0 {
+		return nil, "", nil
+	}
+	var subdomains []string
+	for _, issuance := range issuances {
+		if !c.TimeAfter.IsZero() && (issuance.NotAfter.IsZero() || issuance.NotAfter.Before(c.TimeAfter)) {
+			continue
+		}
+		for _, name := range issuance.DNSNames {
+			// wildcard names are reduced to the domain it covers
+			if name = strings.TrimPrefix(name, "*."); len(name) > 0 {
+				subdomains = append(subdomains, name)
+			}
+		}
+	}
+	return subdomains, issuances[len(issuances)-1].ID, nil
+}
+
+func (c Certspotter) RelatedMethod() string {
+	return base.FromCert
+}
+
+func (c Certspotter) Name() string {
+	return NameCertspotter
+}
@@ -0,0 +1,85 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func NewMockCertspotterServer() *httptest.Server {
+	pages := map[string]string{
+		"": `[
+			{"id": "100", "dns_names": ["bench.com", "www.bench.com"], "not_after": "2023-05-10T23:59:59Z"},
+			{"id": "101", "dns_names": ["*.api.bench.com"], "not_after": "2022-03-15T23:59:59Z"}
+		]`,
+		"101": `[{"id": "205", "dns_names": ["mail.bench.com"], "not_after": "2023-03-15T23:59:59Z"}]`,
+		"205": `[]`,
+	}
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if auth := req.Header.Get("Authorization"); len(auth) > 0 && auth != "Bearer cstoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("include_subdomains") != "true" || req.URL.Query().Get("expand") != "dns_names" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		page, exist := pages[req.URL.Query().Get("after")]
+		if !exist {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+}
+
+func TestCertspotter(t *testing.T) {
+	testSrv := NewMockCertspotterServer()
+	testSrv.Start()
+	defer testSrv.Close()
+
+	testURLBuilder := func(domain string) string {
+		return fmt.Sprintf("%s/v1/issuances?domain=%s&include_subdomains=true&expand=dns_names", testSrv.URL, domain)
+	}
+	cs := NewCertspotter()
+	require.NoError(t, cs.Init(base.UrlBuilder(testURLBuilder), base.QPS(100)))
+	subdomains, err := cs.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	exp := []string{
+		"api.bench.com",
+		"bench.com",
+		"mail.bench.com",
+		"www.bench.com",
+	}
+	assert.Equal(t, exp, subdomains)
+	assert.Equal(t, cs.Stat.DomainsCnt, uint64(1))
+	assert.Equal(t, cs.Stat.SuccessCnt, uint64(1))
+	assert.Equal(t, cs.Stat.PageCnt, uint64(3))
+	assert.Equal(t, cs.Stat.RelatedDomainCnt, uint64(len(exp)))
+
+	// with TimeAfter to filter expired certificate
+	cs = NewCertspotter()
+	require.NoError(t, cs.Init(base.UrlBuilder(testURLBuilder), base.QPS(100), base.APIKeys("cstoken"),
+		base.TimeAfter(time.Date(2022, 5, 31, 0, 0, 0, 0, time.UTC))))
+	subdomains, err = cs.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	assert.Equal(t, []string{"bench.com", "mail.bench.com", "www.bench.com"}, subdomains)
+
+	// wrong token
+	cs = NewCertspotter()
+	require.NoError(t, cs.Init(base.UrlBuilder(testURLBuilder), base.APIKeys("wrong")))
+	_, err = cs.Get(context.Background(), "bench.com")
+	assert.Error(t, err)
+	assert.Equal(t, cs.Stat.ErrCnt, uint64(1))
+}
@@ -3,6 +3,7 @@ package cert
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -45,30 +46,47 @@ func (c *Crtsh) Init(opts ...base.Option) error {
 	c.URLbuilder = func(domain string) string {
 		return "https://crt.sh/?output=json&q=" + domain
 	}
-	c.Parse = func(content []byte) ([]string, error) {
-		var rsp []CrtshRsp
-		if err := json.Unmarshal(content, &rsp); err != nil {
-			return nil, err
+	c.ParseStream = c.parse
+	return c.SDFinder.Init(opts...)
+}
+
+// parse decodes certificates one by one instead of unmarshaling the whole json list, which could be
+// hundreds of MB for large domains. Subdomains are deduplicated while parsing to keep memory bounded
+func (c *Crtsh) parse(r io.Reader) ([]string, error) {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil { // '['
+		return nil, err
+	}
+	var subdomains []string
+	seen := make(map[string]struct{})
+	add := func(subdomain string) {
+		if _, hasseen := seen[subdomain]; len(subdomain) == 0 || hasseen {
+			return
 		}
-		var subdomains []string
-		for _, certificate := range rsp {
-			if !c.TimeAfter.IsZero() {
-				if certificate.NotAfter.IsZero() || certificate.NotAfter.Before(c.TimeAfter) {
-					// skip if 'not_after' can not be parsed or if it's before now, which means this certificate is expired
-					continue
-				}
-			}
-			for _, subdomain := range strings.Split(certificate.NameValue, "\n") {
-				if len(subdomain) > 0 {
-					subdomains = append(subdomains, subdomain)
-				}
+		seen[subdomain] = struct{}{}
+		subdomains = append(subdomains, subdomain)
+	}
+	for dec.More() {
+		var certificate CrtshRsp
+		if err := dec.Decode(&certificate); err != nil {
+			return subdomains, err
+		}
+		if !c.TimeAfter.IsZero() {
+			if certificate.NotAfter.IsZero() || certificate.NotAfter.Before(c.TimeAfter) {
+				// skip if 'not_after' can not be parsed or if it's before now, which means this certificate is expired
+				continue
 			}
-			// value of CommonName can be subdomain or related domains
-			subdomains = append(subdomains, certificate.CommonName)
 		}
-		return subdomains, nil
+		for _, subdomain := range strings.Split(certificate.NameValue, "\n") {
+			add(subdomain)
+		}
+		// value of CommonName can be subdomain or related domains
+		add(certificate.CommonName)
 	}
-	return c.SDFinder.Init(opts...)
+	if _, err := dec.Token(); err != nil { // ']'
+		return subdomains, err
+	}
+	return subdomains, nil
 }
 
 func (c Crtsh) RelatedMethod() string {
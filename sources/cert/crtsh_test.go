@@ -173,3 +173,25 @@ func TestCrtshTimeout(t *testing.T) {
 
 	testSrv.Close()
 }
+
+func TestCrtshMaxResponseBytes(t *testing.T) {
+	testSrv := NewMockServer()
+	testSrv.Start()
+	defer testSrv.Close()
+
+	testURLBuilder := func(domain string) string { return fmt.Sprintf("%s/?output=json&q=", testSrv.URL) + domain }
+	crtsh := NewCrtsh()
+	require.NoError(t, crtsh.Init(base.UrlBuilder(testURLBuilder), base.MaxResponseBytes(1000, false)))
+	subdomains, err := crtsh.Get(context.Background(), "bench.com")
+	assert.ErrorIs(t, err, base.ErrResponseTooLarge)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, uint64(1), crtsh.Stat.TooLargeCnt)
+
+	// certificates before the limit are parsed
+	crtsh = NewCrtsh()
+	require.NoError(t, crtsh.Init(base.UrlBuilder(testURLBuilder), base.MaxResponseBytes(1000, true)))
+	subdomains, err = crtsh.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.bench.com"}, subdomains)
+	assert.Equal(t, uint64(1), crtsh.Stat.TruncatedCnt)
+}
@@ -0,0 +1,97 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// entry types of TimestampedEntry in RFC 6962
+const (
+	x509Entry    = 0
+	precertEntry = 1
+)
+
+var errShortLeaf = errors.New("leaf input is too short")
+
+// ParseLeaf parses MerkleTreeLeaf of RFC 6962, which is the 'leaf_input' in response of get-entries.
+// The certificate of precert entry is built from its TBSCertificate without signature, so only
+// fields in TBSCertificate are valid
+//
+//	struct {
+//	    Version version; MerkleLeafType leaf_type; // 1 byte each, both are 0
+//	    uint64 timestamp; LogEntryType entry_type;
+//	    select(entry_type) {
+//	        case x509_entry: ASN.1Cert signed_entry;            // opaque<1..2^24-1>
+//	        case precert_entry: opaque issuer_key_hash[32]; TBSCertificate tbs_certificate; // opaque<1..2^24-1>
+//	    }
+//	    CtExtensions extensions;
+//	}
+func ParseLeaf(leaf []byte) (*x509.Certificate, error) {
+	if len(leaf) < 12 {
+		return nil, errShortLeaf
+	}
+	if leaf[0] != 0 || leaf[1] != 0 {
+		return nil, fmt.Errorf("unknown leaf version %d or type %d", leaf[0], leaf[1])
+	}
+	entryType := binary.BigEndian.Uint16(leaf[10:12])
+	rest := leaf[12:]
+	switch entryType {
+	case x509Entry:
+		der, err := readUint24Bytes(rest)
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParseCertificate(der)
+	case precertEntry:
+		if len(rest) < 32 {
+			return nil, errShortLeaf
+		}
+		tbs, err := readUint24Bytes(rest[32:])
+		if err != nil {
+			return nil, err
+		}
+		return parseTBS(tbs)
+	default:
+		return nil, fmt.Errorf("unknown entry type %d", entryType)
+	}
+}
+
+func readUint24Bytes(b []byte) ([]byte, error) {
+	if len(b) < 3 {
+		return nil, errShortLeaf
+	}
+	size := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	if len(b) < 3+size {
+		return nil, errShortLeaf
+	}
+	return b[3 : 3+size], nil
+}
+
+// parseTBS wraps TBSCertificate into certificate with empty signature, so that it could be
+// parsed by x509 package. The signature algorithm is copied from TBSCertificate since it
+// should be the same as the outer one
+func parseTBS(tbs []byte) (*x509.Certificate, error) {
+	var fields struct {
+		Version      asn1.RawValue `asn1:"optional,explicit,tag:0"`
+		SerialNumber asn1.RawValue
+		SigAlg       asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(tbs, &fields); err != nil {
+		return nil, fmt.Errorf("parse tbs certificate error: %v", err)
+	}
+	der, err := asn1.Marshal(struct {
+		TBS       asn1.RawValue
+		SigAlg    asn1.RawValue
+		Signature asn1.BitString
+	}{
+		TBS:    asn1.RawValue{FullBytes: tbs},
+		SigAlg: asn1.RawValue{FullBytes: fields.SigAlg.FullBytes},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
@@ -0,0 +1,360 @@
+package cert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// <log>/ct/v1/get-sth returns the size of log, E.g., {"tree_size": 1234, "timestamp": 1660000000000, ...}
+// <log>/ct/v1/get-entries?start=<start>&end=<end> returns entries in [start, end], the log might return
+// less entries than asked, E.g., {"entries": [{"leaf_input": "<base64>", "extra_data": "<base64>"}]}
+//
+// Logs are tailed from the index in checkpoint to the latest size in the first query, names in CN and
+// SAN of certificates are indexed by eTLD+1 and matched with domains in each query. Indexed names are
+// appended to '<checkpoint>.names' along with expiry of certificate before the checkpoint moves, and
+// loaded in next run, so that names read in previous runs are matched with domains given later
+const (
+	NameCTLog = "ctlog"
+
+	// ParamLogs is the param of log urls joined with ',', which is required
+	ParamLogs = "logs"
+	// ParamCheckpoint is the param of checkpoint file which records next index of each log, which is required
+	ParamCheckpoint = "checkpoint"
+	// ParamMaxEntries is the param of max entries to read from each log in one run, older entries
+	// are skipped if the log grows more than it since last run
+	ParamMaxEntries = "max_entries"
+	// ParamBatch is the param of entries asked in one get-entries request
+	ParamBatch = "batch"
+
+	DefaultCTLogMaxEntries = 10000
+	DefaultCTLogBatch      = 256
+)
+
+func init() {
+	base.MustRegister(NameCTLog, NewCTLog())
+}
+
+// CTCheckpoint records next index to read of each log in json file
+type CTCheckpoint struct {
+	path string
+	next map[string]int64
+}
+
+// LoadCTCheckpoint loads checkpoint from file, it's empty if the file does not exist
+func LoadCTCheckpoint(path string) (*CTCheckpoint, error) {
+	cp := &CTCheckpoint{path: path, next: make(map[string]int64)}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		return nil, fmt.Errorf("read ctlog checkpoint %q error: %v", path, err)
+	}
+	if err := json.Unmarshal(buf, &cp.next); err != nil {
+		return nil, fmt.Errorf("unmarshal ctlog checkpoint %q error: %v", path, err)
+	}
+	return cp, nil
+}
+
+// Next returns next index to read of the log, false if the log has not been read
+func (cp *CTCheckpoint) Next(log string) (int64, bool) {
+	next, exist := cp.next[log]
+	return next, exist
+}
+
+// Set records next index of the log and saves the checkpoint
+func (cp *CTCheckpoint) Set(log string, next int64) error {
+	cp.next[log] = next
+	buf, err := json.MarshalIndent(cp.next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cp.path), 0755); err != nil {
+		return err
+	}
+	// write to temp file and rename it, so the checkpoint is not broken if the process is killed
+	tmp, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cp.path)
+}
+
+type CTLog struct {
+	base.SDFinder
+	Logs       []string
+	MaxEntries int
+	Batch      int
+
+	mu         sync.Mutex
+	checkpoint *CTCheckpoint
+	tailed     map[string]bool                 // logs that have been read to the latest size in this run
+	index      map[string]map[string]time.Time // eTLD+1 -> name -> latest not after of certificates
+	namesPath  string                          // names file next to checkpoint
+	pending    []string                        // lines of names indexed but not saved yet
+}
+
+type CTLogSTH struct {
+	TreeSize int64 `json:"tree_size"`
+}
+
+type CTLogEntries struct {
+	Entries []struct {
+		LeafInput []byte `json:"leaf_input"`
+	} `json:"entries"`
+}
+
+func NewCTLog() *CTLog {
+	return &CTLog{SDFinder: *base.NewSDFinder()}
+}
+
+func (cl *CTLog) Init(opts ...base.Option) error {
+	if err := cl.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	cl.Logs = nil
+	for _, log := range strings.Split(cl.StringParam(ParamLogs, ""), ",") {
+		if log = strings.TrimSpace(log); len(log) > 0 {
+			cl.Logs = append(cl.Logs, strings.TrimSuffix(log, "/")+"/")
+		}
+	}
+	if len(cl.Logs) == 0 {
+		return fmt.Errorf("param %q is required for %s", ParamLogs, cl.Name())
+	}
+	checkpoint := cl.StringParam(ParamCheckpoint, "")
+	if len(checkpoint) == 0 {
+		return fmt.Errorf("param %q is required for %s", ParamCheckpoint, cl.Name())
+	}
+	var err error
+	if cl.MaxEntries, err = cl.IntParam(ParamMaxEntries, DefaultCTLogMaxEntries); err != nil {
+		return err
+	}
+	if cl.Batch, err = cl.IntParam(ParamBatch, DefaultCTLogBatch); err != nil {
+		return err
+	}
+	if cl.checkpoint, err = LoadCTCheckpoint(checkpoint); err != nil {
+		return err
+	}
+	cl.tailed = make(map[string]bool)
+	cl.index = make(map[string]map[string]time.Time)
+	cl.namesPath = checkpoint + ".names"
+	return cl.loadNames()
+}
+
+// loadNames indexes names saved in previous runs, names of expired certificates are skipped
+func (cl *CTLog) loadNames() error {
+	f, err := os.Open(cl.namesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read ctlog names %q error: %v", cl.namesPath, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, expiry, found := strings.Cut(scanner.Text(), "\t")
+		if !found {
+			continue
+		}
+		secs, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			continue
+		}
+		cl.indexName(name, time.Unix(secs, 0))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read ctlog names %q error: %v", cl.namesPath, err)
+	}
+	cl.pending = nil
+	return nil
+}
+
+// saveNames appends names indexed since last save to names file
+func (cl *CTLog) saveNames() error {
+	if len(cl.pending) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cl.namesPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cl.namesPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(strings.Join(cl.pending, "\n") + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	cl.pending = cl.pending[:0]
+	return nil
+}
+
+// tailAll reads all logs to the latest size once, logs that fail are read again in next query
+// from the index where it stops
+func (cl *CTLog) tailAll(ctx context.Context) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for _, log := range cl.Logs {
+		if cl.tailed[log] {
+			continue
+		}
+		if err := cl.tail(ctx, log); err != nil {
+			return fmt.Errorf("tail %s error: %w", log, err)
+		}
+		cl.tailed[log] = true
+	}
+	return nil
+}
+
+func (cl *CTLog) tail(ctx context.Context, log string) error {
+	content, err := cl.Fetch(ctx, log+"ct/v1/get-sth")
+	if err != nil {
+		return err
+	}
+	var sth CTLogSTH
+	if err := json.Unmarshal(content, &sth); err != nil {
+		return err
+	}
+	start, _ := cl.checkpoint.Next(log)
+	if skipped := sth.TreeSize - start - int64(cl.MaxEntries); skipped > 0 {
+		atomic.AddUint64(&cl.Stat.LimitSkipCnt, uint64(skipped))
+		start = sth.TreeSize - int64(cl.MaxEntries)
+	}
+	for start < sth.TreeSize {
+		end := min(start+int64(cl.Batch), sth.TreeSize) - 1
+		content, err := cl.Fetch(ctx, log+"ct/v1/get-entries?start="+strconv.FormatInt(start, 10)+"&end="+strconv.FormatInt(end, 10))
+		if err != nil {
+			return err
+		}
+		var entries CTLogEntries
+		if err := json.Unmarshal(content, &entries); err != nil {
+			return err
+		}
+		if len(entries.Entries) == 0 {
+			return fmt.Errorf("no entry returned from %d to %d", start, end)
+		}
+		atomic.AddUint64(&cl.Stat.PageCnt, uint64(1))
+		for _, entry := range entries.Entries {
+			cl.add(entry.LeafInput)
+		}
+		start += int64(len(entries.Entries))
+		// names are saved before checkpoint moves, so that they are not lost if the process is killed
+		if err := cl.saveNames(); err != nil {
+			return fmt.Errorf("save ctlog names error: %v", err)
+		}
+		if err := cl.checkpoint.Set(log, start); err != nil {
+			return fmt.Errorf("save ctlog checkpoint error: %v", err)
+		}
+	}
+	return nil
+}
+
+// add indexes CN and SAN dns names of the certificate in leaf, entries that can not be parsed
+// or are expired are skipped
+func (cl *CTLog) add(leaf []byte) {
+	cert, err := ParseLeaf(leaf)
+	if err != nil {
+		return
+	}
+	if !cl.TimeAfter.IsZero() && cert.NotAfter.Before(cl.TimeAfter) {
+		return
+	}
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		name = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(name), "*."), ".")
+		// names are saved in lines separated by tab
+		if len(name) == 0 || net.ParseIP(name) != nil || strings.ContainsAny(name, " \t\r\n") {
+			continue
+		}
+		if cl.indexName(name, cert.NotAfter) {
+			cl.pending = append(cl.pending, name+"\t"+strconv.FormatInt(cert.NotAfter.Unix(), 10))
+		}
+	}
+}
+
+// indexName indexes the name by eTLD+1, names of expired certificates are skipped. It returns false
+// if the name is not indexed or has been indexed with later expiry
+func (cl *CTLog) indexName(name string, notAfter time.Time) bool {
+	if !cl.TimeAfter.IsZero() && notAfter.Before(cl.TimeAfter) {
+		return false
+	}
+	etld1, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return false
+	}
+	if _, exist := cl.index[etld1]; !exist {
+		cl.index[etld1] = make(map[string]time.Time)
+	}
+	if prev, exist := cl.index[etld1][name]; exist && !notAfter.After(prev) {
+		return false
+	}
+	cl.index[etld1][name] = notAfter
+	return true
+}
+
+// match returns indexed names which are the domain or under the domain
+func (cl *CTLog) match(domain string) []string {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	domain = strings.ToLower(domain)
+	etld1, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return nil
+	}
+	var subdomains []string
+	for name := range cl.index[etld1] {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			subdomains = append(subdomains, name)
+		}
+	}
+	sort.Strings(subdomains)
+	return subdomains
+}
+
+func (cl *CTLog) Get(ctx context.Context, domain string) (subdomains []string, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		cl.RecordStat(subdomains, err)
+	}()
+	if err := cl.tailAll(ctx); err != nil {
+		return nil, err
+	}
+	return cl.match(domain), nil
+}
+
+func (cl *CTLog) RelatedMethod() string {
+	return base.FromCert
+}
+
+func (cl *CTLog) Name() string {
+	return NameCTLog
+}
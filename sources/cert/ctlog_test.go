@@ -0,0 +1,192 @@
+package cert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func newTestCert(t *testing.T, cn string, notAfter time.Time, dnsNames ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// newTestLeaf builds MerkleTreeLeaf of x509 entry, or precert entry with TBSCertificate of the cert
+func newTestLeaf(cert *x509.Certificate, precert bool) []byte {
+	leaf := make([]byte, 12)
+	binary.BigEndian.PutUint64(leaf[2:10], uint64(time.Now().UnixMilli()))
+	data := cert.Raw
+	if precert {
+		binary.BigEndian.PutUint16(leaf[10:12], precertEntry)
+		leaf = append(leaf, make([]byte, 32)...) // issuer key hash
+		data = cert.RawTBSCertificate
+	}
+	leaf = append(leaf, byte(len(data)>>16), byte(len(data)>>8), byte(len(data)))
+	leaf = append(leaf, data...)
+	return append(leaf, 0, 0) // empty extensions
+}
+
+func TestParseLeaf(t *testing.T) {
+	cert := newTestCert(t, "www.bench.com", time.Now().AddDate(1, 0, 0), "www.bench.com", "api.bench.com")
+	for _, precert := range []bool{false, true} {
+		parsed, err := ParseLeaf(newTestLeaf(cert, precert))
+		require.NoError(t, err)
+		assert.Equal(t, "www.bench.com", parsed.Subject.CommonName)
+		assert.Equal(t, []string{"www.bench.com", "api.bench.com"}, parsed.DNSNames)
+		assert.Equal(t, cert.NotAfter, parsed.NotAfter)
+	}
+	leaf := newTestLeaf(cert, false)
+	_, err := ParseLeaf(leaf[:20])
+	assert.Error(t, err)
+	leaf[11] = 5
+	_, err = ParseLeaf(leaf)
+	assert.Error(t, err)
+}
+
+// NewMockCTLogServer serves given leaves, at most 2 entries are returned in one get-entries request
+func NewMockCTLogServer(leaves *[][]byte, reqCnt *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/log/ct/v1/get-sth", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{"tree_size": %d, "timestamp": %d}`, len(*leaves), time.Now().UnixMilli())
+	})
+	mux.HandleFunc("/log/ct/v1/get-entries", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(reqCnt, 1)
+		start, err1 := strconv.Atoi(req.URL.Query().Get("start"))
+		end, err2 := strconv.Atoi(req.URL.Query().Get("end"))
+		if err1 != nil || err2 != nil || start > end || end >= len(*leaves) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		end = min(end, start+1)
+		var rsp struct {
+			Entries []map[string][]byte `json:"entries"`
+		}
+		for _, leaf := range (*leaves)[start : end+1] {
+			rsp.Entries = append(rsp.Entries, map[string][]byte{"leaf_input": leaf, "extra_data": {}})
+		}
+		json.NewEncoder(w).Encode(rsp)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCTLog(t *testing.T) {
+	valid, expired := time.Now().AddDate(1, 0, 0), time.Now().AddDate(-1, 0, 0)
+	leaves := [][]byte{
+		newTestLeaf(newTestCert(t, "bench.com", valid, "bench.com", "*.www.bench.com"), false),
+		newTestLeaf(newTestCert(t, "mail.bench.com", valid, "mail.bench.com", "other.com"), true),
+		newTestLeaf(newTestCert(t, "old.bench.com", expired, "old.bench.com"), false),
+		{0, 0, 1}, // invalid leaf is skipped
+		newTestLeaf(newTestCert(t, "api.bench.co.uk", valid, "api.bench.co.uk"), false),
+	}
+	var reqCnt int32
+	testSrv := NewMockCTLogServer(&leaves, &reqCnt)
+	defer testSrv.Close()
+
+	checkpoint := filepath.Join(t.TempDir(), "ctlog.json")
+	opts := []base.Option{
+		base.QPS(100),
+		base.TimeAfter(time.Now()),
+		base.Param(ParamLogs, testSrv.URL+"/log"),
+		base.Param(ParamCheckpoint, checkpoint),
+	}
+	cl := NewCTLog()
+	require.NoError(t, cl.Init(opts...))
+	assert.Equal(t, []string{testSrv.URL + "/log/"}, cl.Logs)
+	subdomains, err := cl.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bench.com", "mail.bench.com", "www.bench.com"}, subdomains)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&reqCnt))
+	assert.Equal(t, uint64(3), cl.Stat.PageCnt)
+
+	// logs are read once in a run
+	subdomains, err = cl.Get(context.Background(), "bench.co.uk")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.bench.co.uk"}, subdomains)
+	subdomains, err = cl.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&reqCnt))
+	assert.Equal(t, uint64(3), cl.Stat.SuccessCnt)
+	assert.Equal(t, uint64(1), cl.Stat.NotFoundCnt)
+
+	cp, err := LoadCTCheckpoint(checkpoint)
+	require.NoError(t, err)
+	next, exist := cp.Next(testSrv.URL + "/log/")
+	assert.True(t, exist)
+	assert.Equal(t, int64(len(leaves)), next)
+
+	// next run starts from checkpoint, while names read in previous runs are still matched
+	leaves = append(leaves, newTestLeaf(newTestCert(t, "new.bench.com", valid, "new.bench.com"), false))
+	cl = NewCTLog()
+	require.NoError(t, cl.Init(opts...))
+	subdomains, err = cl.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bench.com", "mail.bench.com", "new.bench.com", "www.bench.com"}, subdomains)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&reqCnt))
+	subdomains, err = cl.Get(context.Background(), "bench.co.uk")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.bench.co.uk"}, subdomains)
+
+	// names expired since last run are not matched
+	cl = NewCTLog()
+	require.NoError(t, cl.Init(append(opts, base.TimeAfter(valid.AddDate(0, 0, 1)))...))
+	subdomains, err = cl.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+
+	// only the latest entries are read if the log grows more than max entries
+	cl = NewCTLog()
+	require.NoError(t, cl.Init(append(opts, base.Param(ParamCheckpoint, filepath.Join(t.TempDir(), "ctlog.json")),
+		base.Param(ParamMaxEntries, "2"))...))
+	subdomains, err = cl.Get(context.Background(), "bench.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new.bench.com"}, subdomains)
+	assert.Equal(t, uint64(len(leaves)-2), cl.Stat.LimitSkipCnt)
+}
+
+func TestCTLogError(t *testing.T) {
+	cl := NewCTLog()
+	require.NoError(t, cl.Init(base.Param(ParamLogs, "http://127.0.0.1:0/log"),
+		base.Param(ParamCheckpoint, filepath.Join(t.TempDir(), "ctlog.json"))))
+	_, err := cl.Get(context.Background(), "bench.com")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), cl.Stat.ErrCnt)
+
+	logs, checkpoint := base.Param(ParamLogs, "http://127.0.0.1:0/log"), base.Param(ParamCheckpoint, filepath.Join(t.TempDir(), "ctlog.json"))
+	assert.Error(t, NewCTLog().Init(logs, checkpoint, base.Param(ParamBatch, "0")))
+	// logs and checkpoint are required
+	assert.Error(t, NewCTLog().Init(checkpoint))
+	assert.Error(t, NewCTLog().Init(base.Param(ParamLogs, " , "), checkpoint))
+	assert.Error(t, NewCTLog().Init(logs))
+}
@@ -0,0 +1,177 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// https://dnsdumpster.com/
+// GET the page to obtain csrf token in cookie and form, then POST the search form with the token
+// and parse the host records table, E.g.,
+// <tr><td class="col-md-4">www.bench.com<br></td><td class="col-md-3">1.1.1.1<br><span>...</span></td>
+// <td class="col-md-3">AS13335 CLOUDFLARENET<br><span>United States</span></td></tr>
+const (
+	NameDNSDumpster = "dnsdumpster"
+
+	// keys of extra information in output
+	DNSDumpsterInfoIP  = "ip"
+	DNSDumpsterInfoASN = "asn"
+
+	dnsDumpsterCSRF = "csrfmiddlewaretoken"
+)
+
+func init() {
+	base.MustRegister(NameDNSDumpster, NewDNSDumpster())
+}
+
+type DNSDumpster struct{ base.SDFinder }
+
+func NewDNSDumpster() *DNSDumpster {
+	return &DNSDumpster{*base.NewSDFinder()}
+}
+
+func (dd *DNSDumpster) Init(opts ...base.Option) error {
+	dd.URLbuilder = func(string) string {
+		return "https://dnsdumpster.com/"
+	}
+	return dd.SDFinder.Init(opts...)
+}
+
+func (dd DNSDumpster) RelatedMethod() string {
+	return base.FromCrawl
+}
+
+func (dd DNSDumpster) Name() string {
+	return NameDNSDumpster
+}
+
+// csrfToken parses the token in search form
+func csrfToken(content []byte) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	token, _ := doc.Find(`input[name="` + dnsDumpsterCSRF + `"]`).First().Attr("value")
+	if len(token) == 0 {
+		return "", fmt.Errorf("csrf token not found")
+	}
+	return token, nil
+}
+
+// firstText returns the first non-empty text in selection, which skips the texts after '<br>'
+func firstText(s *goquery.Selection) string {
+	var text string
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			if text = strings.TrimSpace(n.Data); len(text) > 0 {
+				return true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, n := range s.Nodes {
+		if walk(n) {
+			return text
+		}
+	}
+	return ""
+}
+
+// parseDNSDumpster parses hostnames under the domain along with their ips and asn from tables,
+// the ips of the same hostname are joined with ','
+func parseDNSDumpster(content []byte, domain string) ([]string, base.ExInfo, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	var subdomains []string
+	info := make(base.ExInfo)
+	doc.Find(`table tr`).Each(func(_ int, tr *goquery.Selection) {
+		tds := tr.Find(`td`)
+		if tds.Length() < 3 {
+			return
+		}
+		// MX records are prefixed with priority, E.g., '10 mail.bench.com.'
+		fields := strings.Fields(firstText(tds.Eq(0)))
+		if len(fields) == 0 {
+			return
+		}
+		hostname := strings.ToLower(strings.TrimSuffix(fields[len(fields)-1], "."))
+		if hostname != domain && !strings.HasSuffix(hostname, "."+domain) {
+			// NS and MX records are not always under the domain
+			return
+		}
+		if len(info[hostname]) == 0 {
+			subdomains = append(subdomains, hostname)
+			info[hostname] = make(map[string]string)
+		}
+		if ip := firstText(tds.Eq(1)); len(ip) > 0 {
+			ips := info.Get(hostname, DNSDumpsterInfoIP)
+			if len(ips) == 0 {
+				info.Set(hostname, DNSDumpsterInfoIP, ip)
+			} else if !strings.Contains(","+ips+",", ","+ip+",") {
+				info.Set(hostname, DNSDumpsterInfoIP, ips+","+ip)
+			}
+		}
+		if asn := firstText(tds.Eq(2)); strings.HasPrefix(asn, "AS") {
+			info.Set(hostname, DNSDumpsterInfoASN, asn)
+		}
+	})
+	return subdomains, info, nil
+}
+
+// GetWithInfo gets csrf token and posts the search form in one session, so that cookies
+// are not shared between queries
+func (dd *DNSDumpster) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		dd.RecordStat(subdomains, err)
+	}()
+	session, err := dd.Session()
+	if err != nil {
+		return nil, nil, err
+	}
+	pageURL := dd.URLbuilder(domain)
+	content, err := session.Fetch(ctx, pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := csrfToken(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	form := url.Values{dnsDumpsterCSRF: {token}, "targetip": {domain}, "user": {"free"}}
+	content, err = session.FetchRequest(ctx, base.Request{
+		Method: http.MethodPost,
+		URL:    pageURL,
+		Body:   []byte(form.Encode()),
+		Header: http.Header{
+			"Content-Type": {"application/x-www-form-urlencoded"},
+			"Referer":      {pageURL},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseDNSDumpster(content, strings.ToLower(domain))
+}
+
+func (dd *DNSDumpster) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := dd.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
@@ -0,0 +1,83 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func NewMockDNSDumpsterServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fpath := "testdata/dnsdumpster_form.html"
+		switch req.Method {
+		case http.MethodGet:
+			http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "Tk3x9pQ2vL"})
+		case http.MethodPost:
+			cookie, err := req.Cookie("csrftoken")
+			if err != nil || cookie.Value != req.PostFormValue("csrfmiddlewaretoken") ||
+				req.Referer() == "" || req.PostFormValue("targetip") != "bench.com" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fpath = "testdata/dnsdumpster.html"
+		}
+		content, err := os.ReadFile(fpath)
+		if err != nil {
+			http.Error(w, "read file error", http.StatusInternalServerError)
+			return
+		}
+		w.Write(content)
+	}))
+}
+
+func TestDNSDumpster(t *testing.T) {
+	testSrv := NewMockDNSDumpsterServer()
+	testSrv.Start()
+	defer testSrv.Close()
+
+	testURLBuilder := func(string) string { return testSrv.URL + "/" }
+	dd := NewDNSDumpster()
+	require.NoError(t, dd.Init(base.UrlBuilder(testURLBuilder), base.QPS(100)))
+	subdomains, info, err := dd.GetWithInfo(context.Background(), "bench.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	exp := []string{"bench.com", "mail.bench.com", "vpn.bench.com", "www.bench.com"}
+	assert.Equal(t, exp, subdomains)
+	assert.Equal(t, map[string]string{
+		DNSDumpsterInfoIP:  "1.1.1.1,1.1.1.2",
+		DNSDumpsterInfoASN: "AS13335 CLOUDFLARENET",
+	}, info["www.bench.com"])
+	assert.Equal(t, "8.8.8.8", info.Get("vpn.bench.com", DNSDumpsterInfoIP))
+	assert.Equal(t, "AS15169 GOOGLE", info.Get("vpn.bench.com", DNSDumpsterInfoASN))
+	assert.Equal(t, dd.Stat.DomainsCnt, uint64(1))
+	assert.Equal(t, dd.Stat.SuccessCnt, uint64(1))
+	assert.Equal(t, dd.Stat.RelatedDomainCnt, uint64(len(exp)))
+	// cookies are only kept in the session of query
+	assert.Nil(t, dd.Client.Jar)
+
+	// post is rejected
+	subdomains, err = dd.Get(context.Background(), "abc.com")
+	assert.Error(t, err)
+	assert.Empty(t, subdomains)
+	assert.Equal(t, dd.Stat.ErrCnt, uint64(1))
+}
+
+func TestDNSDumpsterNoToken(t *testing.T) {
+	testSrv := NewMockServer("testdata/abuseipdb_notfound.html")
+	testSrv.Start()
+	defer testSrv.Close()
+
+	dd := NewDNSDumpster()
+	require.NoError(t, dd.Init(base.UrlBuilder(func(string) string { return testSrv.URL })))
+	_, err := dd.Get(context.Background(), "bench.com")
+	assert.Error(t, err)
+	assert.Equal(t, dd.Stat.ErrCnt, uint64(1))
+}
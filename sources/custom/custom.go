@@ -0,0 +1,183 @@
+package custom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// InputPlaceholder is replaced by the queried domain or ip in url and body. It's escaped in url
+const InputPlaceholder = "{input}"
+
+const (
+	InputDomain = "domain"
+	InputIP     = "ip"
+)
+
+// Definition describes a source in config, E.g.,
+//
+//	name: internal-dns
+//	url: https://dns.internal/api/v1/subdomains?domain={input}
+//	parser:
+//	  kind: json
+//	  path: data.*.hostname
+type Definition struct {
+	Name          string            `yaml:"name"`
+	URL           string            `yaml:"url"`
+	Method        string            `yaml:"method"` // 'GET'(default) or 'POST'
+	Headers       map[string]string `yaml:"headers"`
+	Body          string            `yaml:"body"`
+	Input         string            `yaml:"input"`          // 'domain'(default) or 'ip'
+	RelatedMethod string            `yaml:"related_method"` // 'api'(default), 'crawl' or 'cert'
+	RelatedType   string            `yaml:"related_type"`   // 'subdomain', 'related-domain' or 'reverse', default base on input
+	Parser        ParserDef         `yaml:"parser"`
+}
+
+func (def *Definition) fillDefault() {
+	def.Method = strings.ToUpper(def.Method)
+	if len(def.Method) == 0 {
+		def.Method = http.MethodGet
+	}
+	if len(def.Input) == 0 {
+		def.Input = InputDomain
+	}
+	if len(def.RelatedMethod) == 0 {
+		def.RelatedMethod = base.FromAPI
+	}
+	if len(def.RelatedType) == 0 {
+		def.RelatedType = base.RLPSubdomain
+		if def.Input == InputIP {
+			def.RelatedType = base.RLPRvsDNS
+		}
+	}
+}
+
+func (def Definition) valid() error {
+	if len(def.Name) == 0 {
+		return fmt.Errorf("empty name")
+	}
+	if u, err := url.Parse(def.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid url %q", def.URL)
+	}
+	if def.Method != http.MethodGet && def.Method != http.MethodPost {
+		return fmt.Errorf("method should be %s or %s", http.MethodGet, http.MethodPost)
+	}
+	if len(def.Body) > 0 && def.Method != http.MethodPost {
+		return fmt.Errorf("body is only for %s", http.MethodPost)
+	}
+	if def.Input != InputDomain && def.Input != InputIP {
+		return fmt.Errorf("input should be %s or %s", InputDomain, InputIP)
+	}
+	switch def.RelatedMethod {
+	case base.FromAPI, base.FromCrawl, base.FromCert, base.FromArchive:
+	default:
+		return fmt.Errorf("related method should be %s, %s, %s or %s", base.FromAPI, base.FromCrawl, base.FromCert, base.FromArchive)
+	}
+	switch def.RelatedType {
+	case base.RLPSubdomain, base.RLPRelatedDomain, base.RLPRvsDNS:
+	default:
+		return fmt.Errorf("related type should be %s, %s or %s", base.RLPSubdomain, base.RLPRelatedDomain, base.RLPRvsDNS)
+	}
+	return nil
+}
+
+// Source is the source defined in config, which works as the builtin sources
+type Source struct {
+	base.SDFinder
+	def   Definition
+	parse func([]byte) ([]string, error)
+}
+
+// New builds source from definition, it fails if the definition is invalid
+func New(def Definition) (*Source, error) {
+	def.fillDefault()
+	if err := def.valid(); err != nil {
+		return nil, fmt.Errorf("invalid custom source %q: %v", def.Name, err)
+	}
+	parse, err := def.Parser.build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid parser of custom source %q: %v", def.Name, err)
+	}
+	return &Source{SDFinder: *base.NewSDFinder(), def: def, parse: parse}, nil
+}
+
+// Register builds sources from definitions and registers them to base.SDFinderMap
+func Register(defs ...Definition) error {
+	for _, def := range defs {
+		src, err := New(def)
+		if err != nil {
+			return err
+		}
+		if err := base.Register(def.Name, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Source) Init(opts ...base.Option) error {
+	s.URLbuilder = func(input string) string {
+		return strings.ReplaceAll(s.def.URL, InputPlaceholder, url.QueryEscape(input))
+	}
+	s.Parse = s.parse
+	defOpts := []base.Option{base.Method(s.def.Method)}
+	for key, val := range s.def.Headers {
+		defOpts = append(defOpts, base.Header(key, val))
+	}
+	return s.SDFinder.Init(append(defOpts, opts...)...)
+}
+
+func (s *Source) Get(ctx context.Context, input string) (subdomains []string, err error) {
+	if len(s.def.Body) == 0 {
+		subdomains, err = s.SDFinder.Get(ctx, input)
+	} else {
+		subdomains, err = s.post(ctx, input)
+	}
+	if err != nil || !s.def.Parser.AppendInput {
+		return subdomains, err
+	}
+	for i, prefix := range subdomains {
+		subdomains[i] = prefix + "." + input
+	}
+	return subdomains, nil
+}
+
+func (s *Source) post(ctx context.Context, input string) (subdomains []string, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		s.RecordStat(subdomains, err)
+	}()
+	body := strings.ReplaceAll(s.def.Body, InputPlaceholder, input)
+	content, err := s.FetchWithBody(ctx, s.URLbuilder(input), []byte(body))
+	if err != nil {
+		return nil, err
+	}
+	sbs, err := s.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	return base.Uniq(sbs), nil
+}
+
+func (s Source) Name() string {
+	return s.def.Name
+}
+
+func (s Source) ServeType() base.InputType {
+	if s.def.Input == InputIP {
+		return base.InputIP
+	}
+	return base.InputDomain
+}
+
+func (s Source) RelatedMethod() string {
+	return s.def.RelatedMethod
+}
+
+func (s Source) RelatedType() string {
+	return s.def.RelatedType
+}
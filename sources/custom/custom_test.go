@@ -0,0 +1,131 @@
+package custom
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func TestCustomGet(t *testing.T) {
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "abc.com", req.URL.Query().Get("domain"))
+		w.Write([]byte(`{"data": [{"hostname": "a.abc.com"}, {"hostname": "B.abc.com"}, {"hostname": "a.abc.com"}]}`))
+	}))
+	defer testSrv.Close()
+
+	src, err := New(Definition{
+		Name:    "test-json",
+		URL:     testSrv.URL + "/api?domain={input}",
+		Headers: map[string]string{"X-Token": "token"},
+		Parser:  ParserDef{Kind: ParserJSON, Path: "data.*.hostname"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, src.Init(base.QPS(100)))
+	assert.Equal(t, "test-json", src.Name())
+	assert.Equal(t, base.InputDomain, src.ServeType())
+	assert.Equal(t, base.FromAPI, src.RelatedMethod())
+	assert.Equal(t, base.RLPSubdomain, src.RelatedType())
+	subdomains, err := src.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	sort.Strings(subdomains)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+	assert.Equal(t, uint64(1), src.Stat.SuccessCnt)
+	assert.Equal(t, uint64(2), src.Stat.RelatedDomainCnt)
+}
+
+func TestCustomPost(t *testing.T) {
+	var reqCnt int32
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// fail the first request to check retries
+		if atomic.AddInt32(&reqCnt, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, `{"query": "abc.com"}`, string(body))
+		w.Write([]byte("<ul class=\"sbs\"><li>www</li><li>mail</li></ul>"))
+	}))
+	defer testSrv.Close()
+
+	src, err := New(Definition{
+		Name:          "test-post",
+		URL:           testSrv.URL + "/search",
+		Method:        "post",
+		Headers:       map[string]string{"Content-Type": "application/json"},
+		Body:          `{"query": "{input}"}`,
+		RelatedMethod: base.FromCrawl,
+		Parser:        ParserDef{Kind: ParserHTML, Selector: "ul.sbs li", AppendInput: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, src.Init(base.QPS(100), base.Retries(1, time.Millisecond)))
+	subdomains, err := src.Get(context.Background(), "abc.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.abc.com", "mail.abc.com"}, subdomains)
+	assert.Equal(t, uint64(1), src.Stat.RetryCnt)
+	assert.Equal(t, uint64(1), src.Stat.SuccessCnt)
+	assert.Equal(t, base.FromCrawl, src.RelatedMethod())
+}
+
+func TestCustomIP(t *testing.T) {
+	testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/ip/1.1.1.1", req.URL.Path)
+		w.Write([]byte("a.abc.com,1.1.1.1\nb.abc.com,1.1.1.1\n"))
+	}))
+	defer testSrv.Close()
+
+	src, err := New(Definition{
+		Name:   "test-ip",
+		URL:    testSrv.URL + "/ip/{input}",
+		Input:  InputIP,
+		Parser: ParserDef{Kind: ParserCSV, Column: 0},
+	})
+	require.NoError(t, err)
+	require.NoError(t, src.Init())
+	assert.Equal(t, base.InputIP, src.ServeType())
+	assert.Equal(t, base.RLPRvsDNS, src.RelatedType())
+	subdomains, err := src.Get(context.Background(), "1.1.1.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+}
+
+func TestCustomInvalid(t *testing.T) {
+	parser := ParserDef{Kind: ParserRegex, Pattern: "abc"}
+	for _, def := range []Definition{
+		{URL: "https://abc.com/{input}", Parser: parser},
+		{Name: "test", URL: "abc.com/{input}", Parser: parser},
+		{Name: "test", URL: "https://abc.com/{input}", Method: "PUT", Parser: parser},
+		{Name: "test", URL: "https://abc.com/{input}", Body: "{input}", Parser: parser},
+		{Name: "test", URL: "https://abc.com/{input}", Input: "email", Parser: parser},
+		{Name: "test", URL: "https://abc.com/{input}", RelatedMethod: "dns", Parser: parser},
+		{Name: "test", URL: "https://abc.com/{input}", RelatedType: "cname", Parser: parser},
+		{Name: "test", URL: "https://abc.com/{input}", Parser: ParserDef{Kind: "xml"}},
+	} {
+		_, err := New(def)
+		assert.Error(t, err, def)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	def := Definition{Name: "test-register", URL: "https://abc.com/{input}", Parser: ParserDef{Kind: ParserRegex, Pattern: "abc"}}
+	require.NoError(t, Register(def))
+	defer delete(base.SDFinderMap, def.Name)
+	assert.Contains(t, base.SDFinderMap, def.Name)
+	// name has been registered
+	assert.Error(t, Register(def))
+}
@@ -0,0 +1,198 @@
+package custom
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+const (
+	ParserJSON  = "json"
+	ParserRegex = "regex"
+	ParserCSV   = "csv"
+	ParserHTML  = "html"
+)
+
+// ParserDef describes how to extract subdomains from response
+type ParserDef struct {
+	Kind string `yaml:"kind"` // 'json', 'regex', 'csv' or 'html'
+
+	// json: dot separated keys, '*' for all the elements of list or values of object, and number
+	// for element of list. E.g., 'data.*.hostname'. Value could be string or list of strings
+	Path string `yaml:"path"`
+
+	// regex: the first capture group is used if given, otherwise the whole match
+	Pattern string `yaml:"pattern"`
+
+	// csv: column is 0-based index, column_name finds the index from header. default delimiter is ','
+	Column     int    `yaml:"column"`
+	ColumnName string `yaml:"column_name"`
+	Delimiter  string `yaml:"delimiter"`
+	SkipHeader bool   `yaml:"skip_header"`
+
+	// html: css selector, text of selected element is used if attr is not given
+	Selector string `yaml:"selector"`
+	Attr     string `yaml:"attr"`
+
+	// results are labels of subdomain, which are joined with queried domain
+	AppendInput bool `yaml:"append_input"`
+}
+
+func (pd ParserDef) build() (func([]byte) ([]string, error), error) {
+	switch pd.Kind {
+	case ParserJSON:
+		if len(pd.Path) == 0 {
+			return nil, fmt.Errorf("path should be given for %s parser", ParserJSON)
+		}
+		return pd.parseJSON, nil
+	case ParserRegex:
+		re, err := regexp.Compile(pd.Pattern)
+		if err != nil || len(pd.Pattern) == 0 {
+			return nil, fmt.Errorf("invalid pattern for %s parser: %q", ParserRegex, pd.Pattern)
+		}
+		return func(content []byte) ([]string, error) { return parseRegex(re, content), nil }, nil
+	case ParserCSV:
+		if pd.Column < 0 {
+			return nil, fmt.Errorf("column should >= 0 for %s parser", ParserCSV)
+		}
+		if len([]rune(pd.Delimiter)) > 1 {
+			return nil, fmt.Errorf("delimiter should be one character for %s parser", ParserCSV)
+		}
+		return pd.parseCSV, nil
+	case ParserHTML:
+		if _, err := cascadia.Compile(pd.Selector); err != nil || len(pd.Selector) == 0 {
+			return nil, fmt.Errorf("invalid selector for %s parser: %q", ParserHTML, pd.Selector)
+		}
+		return pd.parseHTML, nil
+	}
+	return nil, fmt.Errorf("parser kind should be %s, %s, %s or %s", ParserJSON, ParserRegex, ParserCSV, ParserHTML)
+}
+
+func (pd ParserDef) parseJSON(content []byte) ([]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	var result []string
+	var walk func(node interface{}, keys []string)
+	walk = func(node interface{}, keys []string) {
+		if len(keys) == 0 {
+			switch val := node.(type) {
+			case string:
+				result = appendNonEmpty(result, val)
+			case []interface{}:
+				for _, elem := range val {
+					if s, ok := elem.(string); ok {
+						result = appendNonEmpty(result, s)
+					}
+				}
+			}
+			return
+		}
+		switch val := node.(type) {
+		case map[string]interface{}:
+			if keys[0] == "*" {
+				for _, child := range val {
+					walk(child, keys[1:])
+				}
+			} else if child, exist := val[keys[0]]; exist {
+				walk(child, keys[1:])
+			}
+		case []interface{}:
+			if keys[0] == "*" {
+				for _, child := range val {
+					walk(child, keys[1:])
+				}
+			} else if idx, err := strconv.Atoi(keys[0]); err == nil && idx >= 0 && idx < len(val) {
+				walk(val[idx], keys[1:])
+			}
+		}
+	}
+	walk(doc, strings.Split(pd.Path, "."))
+	return result, nil
+}
+
+func parseRegex(re *regexp.Regexp, content []byte) []string {
+	var result []string
+	for _, match := range re.FindAllSubmatch(content, -1) {
+		if len(match) > 1 {
+			result = appendNonEmpty(result, string(match[1]))
+		} else {
+			result = appendNonEmpty(result, string(match[0]))
+		}
+	}
+	return result
+}
+
+func (pd ParserDef) parseCSV(content []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	if len(pd.Delimiter) > 0 {
+		r.Comma = []rune(pd.Delimiter)[0]
+	}
+	column := pd.Column
+	if len(pd.ColumnName) > 0 || pd.SkipHeader {
+		header, err := r.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(pd.ColumnName) > 0 {
+			column = -1
+			for i, name := range header {
+				if strings.TrimSpace(name) == pd.ColumnName {
+					column = i
+				}
+			}
+			if column == -1 {
+				return nil, fmt.Errorf("column %q not found in header", pd.ColumnName)
+			}
+		}
+	}
+	var result []string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		if column < len(record) {
+			result = appendNonEmpty(result, record[column])
+		}
+	}
+}
+
+func (pd ParserDef) parseHTML(content []byte) ([]string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	doc.Find(pd.Selector).Each(func(_ int, s *goquery.Selection) {
+		if len(pd.Attr) == 0 {
+			result = appendNonEmpty(result, s.Text())
+		} else if val, exist := s.Attr(pd.Attr); exist {
+			result = appendNonEmpty(result, val)
+		}
+	})
+	return result, nil
+}
+
+func appendNonEmpty(result []string, val string) []string {
+	if val = strings.TrimSpace(val); len(val) > 0 {
+		return append(result, val)
+	}
+	return result
+}
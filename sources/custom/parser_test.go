@@ -0,0 +1,110 @@
+package custom
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserJSON(t *testing.T) {
+	content := []byte(`{
+		"data": [
+			{"hostname": "a.abc.com", "ip": "1.1.1.1"},
+			{"hostname": "b.abc.com", "ip": "1.1.1.2"},
+			{"ip": "1.1.1.3"}
+		],
+		"meta": {"subdomains": ["c.abc.com", "d.abc.com", 1]},
+		"groups": {"x": {"name": "e.abc.com"}, "y": {"name": "f.abc.com"}}
+	}`)
+	for path, exp := range map[string][]string{
+		"data.*.hostname": {"a.abc.com", "b.abc.com"},
+		"data.1.hostname": {"b.abc.com"},
+		"data.5.hostname": nil,
+		"meta.subdomains": {"c.abc.com", "d.abc.com"},
+		"groups.*.name":   {"e.abc.com", "f.abc.com"},
+		"unknown.key":     nil,
+	} {
+		parse, err := ParserDef{Kind: ParserJSON, Path: path}.build()
+		require.NoError(t, err)
+		subdomains, err := parse(content)
+		require.NoError(t, err)
+		sort.Strings(subdomains)
+		assert.Equal(t, exp, subdomains, path)
+	}
+
+	parse, err := ParserDef{Kind: ParserJSON, Path: "data"}.build()
+	require.NoError(t, err)
+	_, err = parse([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestParserRegex(t *testing.T) {
+	content := []byte(`<a href="https://a.abc.com/">a</a> <a href="https://b.abc.com/x">b</a>`)
+	parse, err := ParserDef{Kind: ParserRegex, Pattern: `https://([a-z0-9.-]+\.abc\.com)`}.build()
+	require.NoError(t, err)
+	subdomains, err := parse(content)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+
+	// whole match without capture group
+	parse, err = ParserDef{Kind: ParserRegex, Pattern: `[a-z]+\.abc\.com`}.build()
+	require.NoError(t, err)
+	subdomains, err = parse(content)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+}
+
+func TestParserCSV(t *testing.T) {
+	content := []byte("ip;host\n1.1.1.1;a.abc.com\n1.1.1.2;b.abc.com\n1.1.1.3\n")
+	for _, pd := range []ParserDef{
+		{Kind: ParserCSV, Column: 1, Delimiter: ";", SkipHeader: true},
+		{Kind: ParserCSV, ColumnName: "host", Delimiter: ";"},
+	} {
+		parse, err := pd.build()
+		require.NoError(t, err)
+		subdomains, err := parse(content)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.abc.com", "b.abc.com"}, subdomains)
+	}
+
+	parse, err := ParserDef{Kind: ParserCSV, ColumnName: "domain", Delimiter: ";"}.build()
+	require.NoError(t, err)
+	_, err = parse(content)
+	assert.Error(t, err)
+}
+
+func TestParserHTML(t *testing.T) {
+	content := []byte(`<html><body>
+		<h4>Subdomains</h4>
+		<ul><li>www</li><li> mail </li></ul>
+		<a class="host" href="https://a.abc.com/">a</a>
+	</body></html>`)
+	parse, err := ParserDef{Kind: ParserHTML, Selector: "h4 + ul li"}.build()
+	require.NoError(t, err)
+	subdomains, err := parse(content)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www", "mail"}, subdomains)
+
+	parse, err = ParserDef{Kind: ParserHTML, Selector: "a.host", Attr: "href"}.build()
+	require.NoError(t, err)
+	subdomains, err = parse(content)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://a.abc.com/"}, subdomains)
+}
+
+func TestParserInvalid(t *testing.T) {
+	for _, pd := range []ParserDef{
+		{Kind: "xml"},
+		{Kind: ParserJSON},
+		{Kind: ParserRegex, Pattern: "(abc"},
+		{Kind: ParserCSV, Column: -1},
+		{Kind: ParserCSV, Delimiter: "||"},
+		{Kind: ParserHTML},
+		{Kind: ParserHTML, Selector: "h4 +"},
+	} {
+		_, err := pd.build()
+		assert.Error(t, err, pd)
+	}
+}
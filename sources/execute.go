@@ -2,21 +2,30 @@ package sources
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/netip"
 	"strings"
 	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/shlin168/sdfinder/sources/active"
 	"github.com/shlin168/sdfinder/sources/base"
 )
 
+// NetblockPrefix is the prefix length of netblock around the resolved ipv4, which is swept
+// by queriers that take cidr as input if cidr is not given
+const NetblockPrefix = 24
+
 // Executor controls the workflow from given domain/ip to the result
 // which has a global view among all the sources
 type Executor struct {
 	Querier      Queriers
 	UniDomain    map[string]struct{} // dedup domain for queriers that take domain as input
 	UniIP        map[string]struct{} // dedup ip for queriers that take domain as input
+	UniCIDR      map[string]struct{} // dedup cidr for queriers that take cidr as input
 	UniSubDomain map[string]struct{} // dedup subdomain
 	Stat         *Stat
 }
@@ -34,21 +43,16 @@ type OutRecord struct {
 type Stat struct {
 	DomainsCnt     uint64               `json:"domain,omitempty"`    // unique domains
 	IPsCnt         uint64               `json:"ip,omitempty"`        // unique ips
+	CIDRsCnt       uint64               `json:"cidr,omitempty"`      // unique cidrs
 	Finder         map[string]base.Stat `json:"detail,omitempty"`    // detail info of each finder
 	SubDomainsCnt  uint64               `json:"subdomain,omitempty"` // unique subdomains
 	TotalOutputRow uint64               `json:"out_rows,omitempty"`
+	Partial        bool                 `json:"partial,omitempty"` // canceled before all the queries finish
 }
 
 // NewExecutorWithConfig initialize executor from name of source with default config
-// if no name of source if given, using all available sources
+// if no name of source if given, using the default sources of GenDefaultConfig
 func NewExecutor(worker int, sdns ...string) (*Executor, error) {
-	if len(sdns) == 0 {
-		var defaultSDFinders []string
-		for name := range base.SDFinderMap {
-			defaultSDFinders = append(defaultSDFinders, name)
-		}
-		sdns = defaultSDFinders
-	}
 	cfg := GenDefaultConfig(sdns, worker)
 	return NewExecutorWithConfig(cfg)
 }
@@ -72,6 +76,10 @@ func NewExecutorWithConfig(cfg *Config) (*Executor, error) {
 	if len(e.Querier.GetNames(ipOnly)) > 0 {
 		e.UniIP = make(map[string]struct{})
 	}
+	cidrOnly := func(item *Querier) bool { return item.Client.ServeType() == base.InputCIDR }
+	if len(e.Querier.GetNames(cidrOnly)) > 0 {
+		e.UniCIDR = make(map[string]struct{})
+	}
 	logrus.Infof("init queriers: %v", e.Querier.GetNames(nil))
 	e.Stat.Finder = make(map[string]base.Stat)
 	return e, nil
@@ -88,20 +96,58 @@ func (e *Executor) CollectStat() {
 	e.Stat.Finder = e.Querier.CollectStat()
 }
 
+// Close closes the finders holding connections or files, which should be invoked after
+// all the queriers finish their jobs
+func (e *Executor) Close() error {
+	var errs []error
+	for _, item := range e.Querier {
+		if closer, ok := item.Client.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close %s: %w", item.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MarkPartial marks the statistic as partial if ctx has been canceled, which should be
+// invoked after the output is drained
+func (e *Executor) MarkPartial(ctx context.Context) {
+	if ctx.Err() != nil {
+		e.Stat.Partial = true
+	}
+}
+
 // SendToQueriersAndAggr get the Query item from channel,
 // send Query.Domain to queriers that serve domains, also send Query.IP to queriers that server IPs
-// If domain or ip has been sent before, it will be skipped.
+// and Query.CIDR to queriers that serve CIDRs, the /24 netblock around Query.IP is used if CIDR is not given.
+// If domain, ip or cidr is empty or has been sent before, it will be skipped.
 // The results from queriers are all sent to return channel for further processing
+// Once ctx is canceled, it stops reading from channel and closes the queriers, so that
+// returned channel is closed after the in-flight queries return
 func (e *Executor) SendToQueriersAndAggr(ctx context.Context, qChan <-chan Query) chan Result {
 	domainOnly := func(item *Querier) bool { return item.Client.ServeType() == base.InputDomain }
 	domainQuerierNames := e.Querier.GetNames(domainOnly)
 	ipOnly := func(item *Querier) bool { return item.Client.ServeType() == base.InputIP }
 	ipQuerierNames := e.Querier.GetNames(ipOnly)
+	cidrOnly := func(item *Querier) bool { return item.Client.ServeType() == base.InputCIDR }
+	cidrQuerierNames := e.Querier.GetNames(cidrOnly)
 	go func() {
-		for qItem := range qChan {
+		defer e.Querier.Close(nil)
+		for {
+			var qItem Query
+			select {
+			case <-ctx.Done():
+				return
+			case q, ok := <-qChan:
+				if !ok {
+					return
+				}
+				qItem = q
+			}
 			// send queries to all domains finders
 			if len(domainQuerierNames) > 0 {
-				if _, hasseen := e.UniDomain[qItem.Domain]; !hasseen {
+				if _, hasseen := e.UniDomain[qItem.Domain]; len(qItem.Domain) > 0 && !hasseen {
 					e.UniDomain[qItem.Domain] = struct{}{}
 					atomic.AddUint64(&e.Stat.DomainsCnt, 1)
 					e.Querier.Send(qItem, domainOnly)
@@ -109,19 +155,82 @@ func (e *Executor) SendToQueriersAndAggr(ctx context.Context, qChan <-chan Query
 			}
 			// send queries to all ip finders
 			if len(ipQuerierNames) > 0 {
-				if _, hasseen := e.UniIP[qItem.IP]; !hasseen {
+				if _, hasseen := e.UniIP[qItem.IP]; len(qItem.IP) > 0 && !hasseen {
 					e.UniIP[qItem.IP] = struct{}{}
 					atomic.AddUint64(&e.Stat.IPsCnt, 1)
 					e.Querier.Send(qItem, ipOnly)
 				}
 			}
+			// send queries to all cidr finders
+			if len(cidrQuerierNames) > 0 {
+				if len(qItem.CIDR) == 0 {
+					qItem.CIDR = NetblockOf(qItem.IP)
+				}
+				if _, hasseen := e.UniCIDR[qItem.CIDR]; len(qItem.CIDR) > 0 && !hasseen {
+					e.UniCIDR[qItem.CIDR] = struct{}{}
+					atomic.AddUint64(&e.Stat.CIDRsCnt, 1)
+					e.Querier.Send(qItem, cidrOnly)
+				}
+			}
 		}
-		e.Querier.Close(nil)
 	}()
 	// aggreate results and sends to one output channel
 	return e.Querier.Aggr()
 }
 
+// Permute seeds the permutation with subdomains found so far under each input domain and resolves
+// the variants, the results are flattened as FlattenOutput does. It should be invoked after the output
+// of SendToQueriersAndAggr is drained, and the statistic of permutation is collected along with sources
+func (e *Executor) Permute(ctx context.Context, p *active.Permutation) <-chan OutRecord {
+	seeded := make(map[string]struct{})
+	for subdomain := range e.UniSubDomain {
+		if domain := e.domainOf(subdomain); len(domain) > 0 {
+			p.Seed(domain, subdomain)
+			seeded[domain] = struct{}{}
+		}
+	}
+	var domains []string
+	for domain := range seeded {
+		domains = append(domains, domain)
+	}
+	qs := NewQueriers(p)
+	e.Querier = append(e.Querier, qs...)
+	qs.StartWorkers(ctx, nil)
+	go func() {
+		defer qs.Close(nil)
+		for _, domain := range domains {
+			select {
+			case <-ctx.Done():
+				return
+			case qs[0].In <- Query{Domain: domain}:
+			}
+		}
+	}()
+	return e.FlattenOutput(qs.Aggr())
+}
+
+// domainOf returns the longest input domain which the subdomain is under
+func (e *Executor) domainOf(subdomain string) string {
+	for name := subdomain; strings.Contains(name, "."); {
+		name = name[strings.Index(name, ".")+1:]
+		if _, exist := e.UniDomain[name]; exist {
+			return name
+		}
+	}
+	return ""
+}
+
+// NetblockOf returns the netblock around the ipv4, E.g., '1.2.3.4' -> '1.2.3.0/24'.
+// Empty string is returned if it's not a valid ipv4
+func NetblockOf(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Is4() {
+		return ""
+	}
+	prefix, _ := addr.Prefix(NetblockPrefix)
+	return prefix.String()
+}
+
 // FlattenOutput flattens the output from one line to multiple line
 // [before] root_domain: 'google.com', subdomains: ['abc.google.com', 'abcd.google.com']
 // [after]  root_domain: 'google.com', domain: 'abc.google.com'
@@ -129,13 +238,26 @@ func (e *Executor) SendToQueriersAndAggr(ctx context.Context, qChan <-chan Query
 func (e *Executor) FlattenOutput(inChan chan Result) <-chan OutRecord {
 	outChan := make(chan OutRecord)
 	go func() {
+		quotaWarned := make(map[string]struct{}) // warn only once for each source
 		for sd := range inChan {
 			if sd.Err != nil {
+				if errors.Is(sd.Err, base.ErrCanceled) {
+					continue
+				}
+				if errors.Is(sd.Err, base.ErrQuotaExhausted) {
+					if _, warned := quotaWarned[sd.RelationMethod]; !warned {
+						quotaWarned[sd.RelationMethod] = struct{}{}
+						logrus.WithField("method", sd.RelationMethod).Warn("quota exhausted, skip remaining queries")
+					}
+					continue
+				}
 				switch sd.IType {
 				case base.InputDomain:
 					logrus.WithFields(logrus.Fields{"method": sd.RelationMethod, "domain": sd.Domain}).WithError(sd.Err).Warn("query")
 				case base.InputIP:
 					logrus.WithFields(logrus.Fields{"method": sd.RelationMethod, "ip": sd.IP}).WithError(sd.Err).Warn("query")
+				case base.InputCIDR:
+					logrus.WithFields(logrus.Fields{"method": sd.RelationMethod, "cidr": sd.CIDR}).WithError(sd.Err).Warn("query")
 				}
 				continue
 			}
@@ -150,11 +272,27 @@ func (e *Executor) FlattenOutput(inChan chan Result) <-chan OutRecord {
 					RLPMethod: sd.RelationMethod,
 					RLPType:   sd.RelationType,
 				}
+				if info, exist := sd.ExInfo[subdomain]; exist {
+					out.ExInfo = make(map[string]string)
+					for key, val := range info {
+						out.ExInfo[key] = val
+					}
+				}
 				if sd.IType == base.InputIP {
-					out.ExInfo = map[string]string{"ip": sd.IP}
+					if out.ExInfo == nil {
+						out.ExInfo = make(map[string]string)
+					}
+					out.ExInfo["ip"] = sd.IP
+				}
+				if sd.IType == base.InputCIDR {
+					if out.ExInfo == nil {
+						out.ExInfo = make(map[string]string)
+					}
+					out.ExInfo["cidr"] = sd.CIDR
 				}
-				// change related method the 'related domain' if subdomain is not end with domain
-				if !strings.HasSuffix(subdomain, "."+sd.Domain) {
+				// change related method the 'related domain' if subdomain is not end with domain,
+				// hosts found by sweeping cidr are kept as it is since they are not bound to domain
+				if sd.IType != base.InputCIDR && !strings.HasSuffix(subdomain, "."+sd.Domain) {
 					out.RLPType = base.RLPRelatedDomain
 				}
 				atomic.AddUint64(&e.Stat.TotalOutputRow, uint64(1))
@@ -2,12 +2,15 @@ package sources
 
 import (
 	"context"
+	"errors"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/shlin168/sdfinder/sources/active"
 	"github.com/shlin168/sdfinder/sources/base"
 )
 
@@ -83,3 +86,186 @@ func TestExecute(t *testing.T) {
 	assert.Equal(t, uint64(1), statMap["test3"].SuccessCnt)
 	assert.Equal(t, uint64(1), statMap["test3"].RelatedDomainCnt)
 }
+
+func TestExecuteCanceled(t *testing.T) {
+	exc := &Executor{
+		Querier:      NewQueriers(&Test1{SDFinder: *base.NewSDFinder()}),
+		Stat:         &Stat{Finder: make(map[string]base.Stat)},
+		UniDomain:    make(map[string]struct{}),
+		UniSubDomain: make(map[string]struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exc.StartWorkers(ctx)
+	qChan := make(chan Query) // never closed, the executor should stop reading once canceled
+	var get []OutRecord
+	for out := range exc.FlattenOutput(exc.SendToQueriersAndAggr(ctx, qChan)) {
+		get = append(get, out)
+	}
+	assert.Empty(t, get)
+	exc.CollectStat()
+	exc.MarkPartial(ctx)
+	assert.True(t, exc.Stat.Partial)
+	assert.Equal(t, uint64(0), exc.Stat.Finder["test1"].DomainsCnt)
+}
+
+func TestExecuteExInfo(t *testing.T) {
+	exc := &Executor{
+		Querier:      NewQueriers(&Test4{SDFinder: *base.NewSDFinder()}),
+		Stat:         &Stat{Finder: make(map[string]base.Stat)},
+		UniDomain:    make(map[string]struct{}),
+		UniSubDomain: make(map[string]struct{}),
+	}
+	exc.StartWorkers(context.Background())
+	qChan := make(chan Query)
+	go func() {
+		qChan <- Query{Domain: "abc.com"}
+		close(qChan)
+	}()
+	var get []OutRecord
+	for out := range exc.FlattenOutput(exc.SendToQueriersAndAggr(context.Background(), qChan)) {
+		get = append(get, out)
+	}
+	sort.Slice(get, func(i, j int) bool {
+		return get[i].SubDomain < get[j].SubDomain
+	})
+	assert.Equal(t, []OutRecord{
+		{
+			Domain:    "abc.com",
+			SubDomain: "info.abc.com",
+			RLPMethod: "related4/test4",
+			RLPType:   base.RLPSubdomain,
+			ExInfo:    map[string]string{"ip": "1.1.1.1", "last_seen": "2022-05-30T11:55:07"},
+		}, {
+			Domain:    "abc.com",
+			SubDomain: "noinfo.abc.com",
+			RLPMethod: "related4/test4",
+			RLPType:   base.RLPSubdomain,
+		},
+	}, get)
+}
+
+func TestExecuteCIDR(t *testing.T) {
+	exc := &Executor{
+		Querier:      NewQueriers(&Test1{SDFinder: *base.NewSDFinder()}, &Test5{SDFinder: *base.NewSDFinder()}),
+		Stat:         &Stat{Finder: make(map[string]base.Stat)},
+		UniDomain:    make(map[string]struct{}),
+		UniCIDR:      make(map[string]struct{}),
+		UniSubDomain: make(map[string]struct{}),
+	}
+	exc.StartWorkers(context.Background())
+	qChan := make(chan Query)
+	go func() {
+		// netblock around the ip is swept once
+		qChan <- Query{Domain: "abc.com", IP: "111.222.111.222"}
+		qChan <- Query{Domain: "abc.com", IP: "111.222.111.1"}
+		// cidr only, which is not sent to domain finders
+		qChan <- Query{CIDR: "10.0.0.0/30"}
+		close(qChan)
+	}()
+	var get []OutRecord
+	for out := range exc.FlattenOutput(exc.SendToQueriersAndAggr(context.Background(), qChan)) {
+		get = append(get, out)
+	}
+	sort.Slice(get, func(i, j int) bool {
+		if get[i].SubDomain != get[j].SubDomain {
+			return get[i].SubDomain < get[j].SubDomain
+		}
+		return get[i].Domain < get[j].Domain
+	})
+	assert.Equal(t, []OutRecord{
+		{
+			Domain:    "abc.com",
+			SubDomain: "abc.abc.com",
+			RLPMethod: "related1/test1",
+			RLPType:   base.RLPSubdomain,
+		}, {
+			Domain:    "abc.com",
+			SubDomain: "cde.abc.com",
+			RLPMethod: "related1/test1",
+			RLPType:   base.RLPSubdomain,
+		}, {
+			SubDomain: "sibling.xyz.com",
+			RLPMethod: "related5/test5",
+			RLPType:   base.RLPRvsDNS,
+			ExInfo:    map[string]string{"ip": "111.222.111.1", "cidr": "10.0.0.0/30"},
+		}, {
+			Domain:    "abc.com",
+			SubDomain: "sibling.xyz.com",
+			RLPMethod: "related5/test5",
+			RLPType:   base.RLPRvsDNS,
+			ExInfo:    map[string]string{"ip": "111.222.111.1", "cidr": "111.222.111.0/24"},
+		},
+	}, get)
+	exc.CollectStat()
+	assert.Equal(t, uint64(1), exc.Stat.DomainsCnt)
+	assert.Equal(t, uint64(2), exc.Stat.CIDRsCnt)
+	assert.Equal(t, uint64(1), exc.Stat.Finder["test1"].DomainsCnt)
+	assert.Equal(t, uint64(2), exc.Stat.Finder["test5"].DomainsCnt)
+}
+
+func TestNetblockOf(t *testing.T) {
+	assert.Equal(t, "111.222.111.0/24", NetblockOf("111.222.111.222"))
+	assert.Empty(t, NetblockOf("2001:db8::1"))
+	assert.Empty(t, NetblockOf(""))
+}
+
+func TestExecutePermute(t *testing.T) {
+	exc := &Executor{
+		Querier:      NewQueriers(&Test1{SDFinder: *base.NewSDFinder()}),
+		Stat:         &Stat{Finder: make(map[string]base.Stat)},
+		UniDomain:    map[string]struct{}{"abc.com": {}, "sub.abc.com": {}},
+		UniSubDomain: map[string]struct{}{"api-v2.abc.com": {}, "web.sub.abc.com": {}, "other.com": {}},
+	}
+	assert.Equal(t, "abc.com", exc.domainOf("api-v2.abc.com"))
+	assert.Equal(t, "sub.abc.com", exc.domainOf("web.sub.abc.com"))
+	assert.Empty(t, exc.domainOf("other.com"))
+	assert.Empty(t, exc.domainOf("abc.com"))
+
+	p := active.NewPermutation()
+	// no resolver is listening, every lookup fails
+	require.NoError(t, p.Init(base.Timeout(100*time.Millisecond), base.Param(active.ParamResolvers, "127.0.0.1:1")))
+	var get []OutRecord
+	for out := range exc.Permute(context.Background(), p) {
+		get = append(get, out)
+	}
+	assert.Empty(t, get)
+	exc.CollectStat()
+	assert.Equal(t, uint64(2), exc.Stat.Finder[active.NamePermutation].DomainsCnt)
+	assert.Equal(t, uint64(2), exc.Stat.Finder[active.NamePermutation].ErrCnt)
+	assert.Equal(t, uint64(0), exc.Stat.Finder[active.NamePermutation].ReportedCnt)
+}
+
+type testCloser struct {
+	Test1
+	closed bool
+	err    error
+}
+
+func (tc *testCloser) Close() error {
+	tc.closed = true
+	return tc.err
+}
+
+func TestExecuteClose(t *testing.T) {
+	closer := &testCloser{Test1: Test1{SDFinder: *base.NewSDFinder()}}
+	failed := &testCloser{Test1: Test1{SDFinder: *base.NewSDFinder()}, err: errors.New("close failed")}
+	exc := &Executor{Querier: NewQueriers(closer, &Test2{SDFinder: *base.NewSDFinder()})}
+	require.NoError(t, exc.Close())
+	assert.True(t, closer.closed)
+
+	exc.Querier = append(exc.Querier, NewQueriers(failed)...)
+	assert.ErrorContains(t, exc.Close(), "close failed")
+	assert.True(t, failed.closed)
+}
+
+func TestNewExecutorDefault(t *testing.T) {
+	exc, err := NewExecutor(1)
+	require.NoError(t, err)
+	require.NotEmpty(t, exc.Querier)
+	for _, q := range exc.Querier {
+		assert.NotEqual(t, base.FromActive, q.Client.RelatedMethod(), q.Client.Name())
+		assert.NotEqual(t, base.FromFile, q.Client.RelatedMethod(), q.Client.Name())
+	}
+	require.NoError(t, exc.Close())
+}
@@ -0,0 +1,150 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// FDNS reads Rapid7-style forward dns dump, which is gzip json lines with 'name', 'type' and 'value'.
+// The dump is indexed to sorted files on disk once, so that lookups are binary searches without loading
+// the dump into memory. 'fdns' finds subdomains of domain, while 'fdns/reverse' finds names of A and AAAA records
+// pointing to ip. Lookups read local files only, so they are not limited by qps
+const (
+	NameFDNS    = "fdns"
+	NameFDNSRvs = "fdns/reverse"
+
+	// ParamDump is the param of fdns dump path, E.g., './2022-05-27-fdns_a.json.gz'
+	ParamDump = "dump"
+	// ParamIndex is the param of index directory, default '<dump>.index'
+	ParamIndex = "index"
+	// ParamChunkLines is the param of lines sorted in memory at once when building index
+	ParamChunkLines = "chunk_lines"
+
+	DefaultFDNSChunkLines = 1000000
+)
+
+func init() {
+	base.MustRegister(NameFDNS, NewFDNS())
+	base.MustRegister(NameFDNSRvs, NewFDNSRvs())
+}
+
+type FDNS struct {
+	base.SDFinder
+	Index *Index
+}
+
+func NewFDNS() *FDNS {
+	return &FDNS{SDFinder: *base.NewSDFinder()}
+}
+
+func (fd *FDNS) Init(opts ...base.Option) error {
+	return fd.init(NameFDNS, opts...)
+}
+
+// init opens the index, name is the name of the outer finder shown in error
+func (fd *FDNS) init(name string, opts ...base.Option) error {
+	if err := fd.SDFinder.Init(opts...); err != nil {
+		return err
+	}
+	dump := fd.StringParam(ParamDump, "")
+	if len(dump) == 0 {
+		return fmt.Errorf("param %q is required for %s", ParamDump, name)
+	}
+	chunkLines, err := fd.IntParam(ParamChunkLines, DefaultFDNSChunkLines)
+	if err != nil {
+		return err
+	}
+	fd.Index, err = OpenIndex(dump, fd.StringParam(ParamIndex, dump+".index"), chunkLines)
+	return err
+}
+
+// Get returns names under the domain in the dump, the domain itself is excluded
+func (fd *FDNS) Get(ctx context.Context, domain string) (subdomains []string, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		fd.RecordStat(subdomains, err)
+	}()
+	domain = normalizeName(domain)
+	if len(domain) == 0 {
+		return nil, fmt.Errorf("invalid domain")
+	}
+	err = fd.Index.Domains.Scan(ReverseName(domain)+".", func(line string) bool {
+		subdomains = append(subdomains, ReverseName(line))
+		return ctx.Err() == nil
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subdomains, nil
+}
+
+// Close closes the index, which is closed once all the finders sharing it close it
+func (fd *FDNS) Close() error {
+	if fd.Index == nil {
+		return nil
+	}
+	return fd.Index.Close()
+}
+
+func (fd *FDNS) RelatedMethod() string {
+	return base.FromFile
+}
+
+func (fd *FDNS) Name() string {
+	return NameFDNS
+}
+
+type FDNSRvs struct {
+	FDNS
+}
+
+func NewFDNSRvs() *FDNSRvs {
+	return &FDNSRvs{FDNS{SDFinder: *base.NewSDFinder()}}
+}
+
+func (fdr *FDNSRvs) Init(opts ...base.Option) error {
+	return fdr.FDNS.init(NameFDNSRvs, opts...)
+}
+
+// Get returns names of A and AAAA records pointing to the ip in the dump
+func (fdr *FDNSRvs) Get(ctx context.Context, ip string) (subdomains []string, err error) {
+	defer func() {
+		err = base.CtxErr(ctx, err)
+		fdr.RecordStat(subdomains, err)
+	}()
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid ip %q", ip)
+	}
+	prefix := parsed.String() + "\t"
+	err = fdr.Index.IPs.Scan(prefix, func(line string) bool {
+		subdomains = append(subdomains, strings.TrimPrefix(line, prefix))
+		return ctx.Err() == nil
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subdomains, nil
+}
+
+func (fdr *FDNSRvs) ServeType() base.InputType {
+	return base.InputIP
+}
+
+func (fdr *FDNSRvs) RelatedType() string {
+	return base.RLPRvsDNS
+}
+
+func (fdr *FDNSRvs) Name() string {
+	return NameFDNSRvs
+}
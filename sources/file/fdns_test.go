@@ -0,0 +1,75 @@
+package file
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func TestFDNS(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "fdns.json.gz")
+	writeTestDump(t, dump, testDump...)
+
+	fd := NewFDNS()
+	require.NoError(t, fd.Init(base.Param(ParamDump, dump), base.Param(ParamChunkLines, "3")))
+	defer fd.Close()
+	assert.Equal(t, dump+".index", fd.Index.Dir)
+	assert.Equal(t, "file/fdns", fd.RelatedMethod()+"/"+fd.Name())
+	subdomains, err := fd.Get(context.Background(), "Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.example.com", "a.b.example.com", "mail.example.com", "www.example.com"}, subdomains)
+	subdomains, err = fd.Get(context.Background(), "mail.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	subdomains, err = fd.Get(context.Background(), "b.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.b.example.com"}, subdomains)
+	assert.Equal(t, uint64(2), fd.Stat.FoundCnt)
+	assert.Equal(t, uint64(1), fd.Stat.NotFoundCnt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fd.Get(ctx, "example.com")
+	assert.ErrorIs(t, err, base.ErrCanceled)
+
+	assert.EqualError(t, NewFDNS().Init(), `param "dump" is required for fdns`)
+}
+
+func TestFDNSRvs(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "fdns.json.gz")
+	writeTestDump(t, dump, testDump...)
+	indexDir := filepath.Join(dir, "index")
+
+	fd := NewFDNS()
+	require.NoError(t, fd.Init(base.Param(ParamDump, dump), base.Param(ParamIndex, indexDir)))
+	defer fd.Close()
+	fdr := NewFDNSRvs()
+	require.NoError(t, fdr.Init(base.Param(ParamDump, dump), base.Param(ParamIndex, indexDir)))
+	defer fdr.Close()
+	// index is shared
+	assert.Same(t, fd.Index, fdr.Index)
+	assert.Equal(t, base.InputIP, fdr.ServeType())
+	assert.Equal(t, base.RLPRvsDNS, fdr.RelatedType())
+
+	subdomains, err := fdr.Get(context.Background(), "1.1.1.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.b.example.com", "example.com", "www.example-foo.com", "www.example.com"}, subdomains)
+	subdomains, err = fdr.Get(context.Background(), "2001:0db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.example.com"}, subdomains)
+	subdomains, err = fdr.Get(context.Background(), "1.1.1.4")
+	require.NoError(t, err)
+	assert.Empty(t, subdomains)
+	_, err = fdr.Get(context.Background(), "abc")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), fdr.Stat.ErrCnt)
+
+	assert.EqualError(t, NewFDNSRvs().Init(), `param "dump" is required for fdns/reverse`)
+}
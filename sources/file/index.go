@@ -0,0 +1,536 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	indexDomains = "domains" // reversed names, E.g., 'com.example.www'
+	indexIPs     = "ips"     // '<ip>\t<name>' of A and AAAA records
+	indexMeta    = "meta.json"
+
+	// maxDumpLine is the max length of one line in dump, longer lines are skipped
+	maxDumpLine = 1 << 20
+)
+
+// maxMergeFiles is the max chunk files opened at once in one merge pass, chunks are merged into
+// intermediate chunks in passes if there are more, so that it does not exceed the limit of open files
+var maxMergeFiles = 128
+
+// Index is the on-disk sorted index of fdns dump, which is built once and reused until the dump changes.
+// The index is shared by finders reading the same index directory
+type Index struct {
+	Dir     string
+	Domains *SortedFile
+	IPs     *SortedFile
+	refs    int
+}
+
+// Meta identifies the dump which the index is built from
+type Meta struct {
+	Dump    string    `json:"dump"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+var (
+	indexMu sync.Mutex
+	indexes = make(map[string]*Index) // index dir -> opened index
+)
+
+// FDNSRecord is one line in fdns dump
+type FDNSRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// OpenIndex opens the index in dir, which is built from dump if it does not exist or the dump has changed
+func OpenIndex(dump, dir string, chunkLines int) (*Index, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if idx, exist := indexes[dir]; exist {
+		idx.refs++
+		return idx, nil
+	}
+	fi, err := os.Stat(dump)
+	if err != nil {
+		return nil, err
+	}
+	meta := Meta{Dump: dump, Size: fi.Size(), ModTime: fi.ModTime().UTC()}
+	if meta.Dump, err = filepath.Abs(dump); err != nil {
+		return nil, err
+	}
+	if built, err := readMeta(dir); err != nil || built != meta {
+		start := time.Now()
+		logrus.WithFields(logrus.Fields{"dump": dump, "index": dir}).Info("build fdns index")
+		if err := BuildIndex(dump, dir, chunkLines); err != nil {
+			return nil, err
+		}
+		if err := writeMeta(dir, meta); err != nil {
+			return nil, err
+		}
+		logrus.WithFields(logrus.Fields{"index": dir, "elapsed": time.Since(start)}).Info("fdns index built")
+	}
+	idx := &Index{Dir: dir, refs: 1}
+	if idx.Domains, err = OpenSortedFile(filepath.Join(dir, indexDomains)); err != nil {
+		return nil, err
+	}
+	if idx.IPs, err = OpenSortedFile(filepath.Join(dir, indexIPs)); err != nil {
+		idx.Domains.Close()
+		return nil, err
+	}
+	indexes[dir] = idx
+	return idx, nil
+}
+
+// Close closes the index once all the finders sharing it close it
+func (idx *Index) Close() error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if idx.refs--; idx.refs > 0 {
+		return nil
+	}
+	delete(indexes, idx.Dir)
+	return errors.Join(idx.Domains.Close(), idx.IPs.Close())
+}
+
+func readMeta(dir string) (Meta, error) {
+	var meta Meta
+	buf, err := os.ReadFile(filepath.Join(dir, indexMeta))
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(buf, &meta); err != nil {
+		return meta, err
+	}
+	meta.ModTime = meta.ModTime.UTC()
+	return meta, nil
+}
+
+func writeMeta(dir string, meta Meta) error {
+	buf, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, indexMeta), buf, 0644)
+}
+
+// ReverseName reverses labels of the name so that subdomains are adjacent to the domain
+// in sorted index, E.g., 'www.example.com' -> 'com.example.www'
+func ReverseName(name string) string {
+	labels := strings.Split(name, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// normalizeName returns the lowercase name without trailing dot, empty string is returned if
+// the name can not be stored as one line in index
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if strings.ContainsAny(name, " \t\r\n") {
+		return ""
+	}
+	return name
+}
+
+// BuildIndex reads gzip json lines fdns dump and writes sorted indexes to dir, the lines are sorted in chunks
+// of chunkLines in memory and merged from disk. Lines which can not be parsed are skipped
+func BuildIndex(dump, dir string, chunkLines int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Open(dump)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read gzip %q error: %v", dump, err)
+	}
+	defer gz.Close()
+	domains := &externalSorter{dir: dir, name: indexDomains, limit: chunkLines}
+	defer domains.cleanup()
+	ips := &externalSorter{dir: dir, name: indexIPs, limit: chunkLines}
+	defer ips.cleanup()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), maxDumpLine)
+	var skipped int
+	for scanner.Scan() {
+		var record FDNSRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			skipped++
+			continue
+		}
+		name := normalizeName(record.Name)
+		if len(name) == 0 {
+			skipped++
+			continue
+		}
+		if err := domains.Add(ReverseName(name)); err != nil {
+			return err
+		}
+		switch strings.ToLower(record.Type) {
+		case "a", "aaaa":
+			if ip := net.ParseIP(strings.TrimSpace(record.Value)); ip != nil {
+				if err := ips.Add(ip.String() + "\t" + name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read dump %q error: %v", dump, err)
+	}
+	if skipped > 0 {
+		logrus.WithFields(logrus.Fields{"dump": dump, "skipped": skipped}).Warn("skip invalid lines in fdns dump")
+	}
+	if err := domains.Finish(filepath.Join(dir, indexDomains)); err != nil {
+		return err
+	}
+	return ips.Finish(filepath.Join(dir, indexIPs))
+}
+
+// externalSorter sorts lines which do not fit in memory, lines are sorted and written to chunk files
+// once the amount reaches limit, then chunks are merged into one sorted file without duplicates
+type externalSorter struct {
+	dir    string
+	name   string
+	limit  int
+	lines  []string
+	chunks []string
+}
+
+func (es *externalSorter) Add(line string) error {
+	es.lines = append(es.lines, line)
+	if len(es.lines) >= es.limit {
+		return es.flush()
+	}
+	return nil
+}
+
+func (es *externalSorter) flush() error {
+	if len(es.lines) == 0 {
+		return nil
+	}
+	sort.Strings(es.lines)
+	f, err := os.CreateTemp(es.dir, es.name+"-*.chunk")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	es.chunks = append(es.chunks, f.Name())
+	w := bufio.NewWriter(f)
+	for i, line := range es.lines {
+		if i > 0 && line == es.lines[i-1] {
+			continue
+		}
+		if _, err := w.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	es.lines = es.lines[:0]
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Finish merges chunks into sorted file at path, at most maxMergeFiles chunks are merged in one pass
+func (es *externalSorter) Finish(path string) error {
+	if err := es.flush(); err != nil {
+		return err
+	}
+	for len(es.chunks) > maxMergeFiles {
+		if err := es.mergePass(); err != nil {
+			return err
+		}
+	}
+	sw, err := NewSortedWriter(path)
+	if err != nil {
+		return err
+	}
+	defer sw.Abort()
+	if err := mergeChunks(es.chunks, sw.Write); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// mergePass merges every maxMergeFiles chunks into one intermediate chunk, merged chunks are removed
+func (es *externalSorter) mergePass() error {
+	var merged []string
+	for start := 0; start < len(es.chunks); start += maxMergeFiles {
+		group := es.chunks[start:min(start+maxMergeFiles, len(es.chunks))]
+		chunk, err := es.mergeToChunk(group)
+		if err != nil {
+			// keep all chunks to be removed in cleanup
+			es.chunks = append(es.chunks, merged...)
+			return err
+		}
+		merged = append(merged, chunk)
+		for _, c := range group {
+			os.Remove(c)
+		}
+	}
+	es.chunks = merged
+	return nil
+}
+
+func (es *externalSorter) mergeToChunk(chunks []string) (string, error) {
+	f, err := os.CreateTemp(es.dir, es.name+"-*.chunk")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	err = mergeChunks(chunks, func(line string) error {
+		_, err := w.WriteString(line + "\n")
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// mergeChunks merges sorted chunk files and calls write with each line in order without duplicates
+func mergeChunks(chunks []string, write func(line string) error) error {
+	mh := &mergeHeap{}
+	for _, chunk := range chunks {
+		f, err := os.Open(chunk)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxDumpLine)
+		if scanner.Scan() {
+			mh.items = append(mh.items, mergeItem{line: scanner.Text(), src: scanner})
+		} else if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	heap.Init(mh)
+	var last *string
+	for mh.Len() > 0 {
+		item := &mh.items[0]
+		if last == nil || item.line != *last {
+			if err := write(item.line); err != nil {
+				return err
+			}
+			line := item.line
+			last = &line
+		}
+		if item.src.Scan() {
+			item.line = item.src.Text()
+			heap.Fix(mh, 0)
+		} else {
+			if err := item.src.Err(); err != nil {
+				return err
+			}
+			heap.Pop(mh)
+		}
+	}
+	return nil
+}
+
+// cleanup removes chunk files
+func (es *externalSorter) cleanup() {
+	for _, chunk := range es.chunks {
+		os.Remove(chunk)
+	}
+	es.chunks = nil
+}
+
+type mergeItem struct {
+	line string
+	src  *bufio.Scanner
+}
+
+type mergeHeap struct{ items []mergeItem }
+
+func (mh mergeHeap) Len() int            { return len(mh.items) }
+func (mh mergeHeap) Less(i, j int) bool  { return mh.items[i].line < mh.items[j].line }
+func (mh mergeHeap) Swap(i, j int)       { mh.items[i], mh.items[j] = mh.items[j], mh.items[i] }
+func (mh *mergeHeap) Push(x interface{}) { mh.items = append(mh.items, x.(mergeItem)) }
+func (mh *mergeHeap) Pop() interface{} {
+	item := mh.items[len(mh.items)-1]
+	mh.items = mh.items[:len(mh.items)-1]
+	return item
+}
+
+// SortedWriter writes sorted lines to data file along with offsets file, which stores the start
+// offset of each line and the end offset of the last line in 8 bytes big endian.
+// Files are written to temp files and renamed once closed
+type SortedWriter struct {
+	path       string
+	data, offs *os.File
+	dw, ow     *bufio.Writer
+	offset     uint64
+}
+
+func NewSortedWriter(path string) (*SortedWriter, error) {
+	data, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	offs, err := os.Create(path + ".off.tmp")
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return &SortedWriter{path: path, data: data, offs: offs, dw: bufio.NewWriter(data), ow: bufio.NewWriter(offs)}, nil
+}
+
+func (sw *SortedWriter) Write(line string) error {
+	if _, err := sw.ow.Write(binary.BigEndian.AppendUint64(nil, sw.offset)); err != nil {
+		return err
+	}
+	n, err := sw.dw.WriteString(line + "\n")
+	sw.offset += uint64(n)
+	return err
+}
+
+// Close flushes the files and renames them to the path
+func (sw *SortedWriter) Close() error {
+	if _, err := sw.ow.Write(binary.BigEndian.AppendUint64(nil, sw.offset)); err != nil {
+		return err
+	}
+	for _, w := range []*bufio.Writer{sw.dw, sw.ow} {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, f := range []*os.File{sw.data, sw.offs} {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(sw.data.Name(), sw.path); err != nil {
+		return err
+	}
+	return os.Rename(sw.offs.Name(), sw.path+".off")
+}
+
+// Abort removes the temp files if the writer is not closed
+func (sw *SortedWriter) Abort() {
+	for _, f := range []*os.File{sw.data, sw.offs} {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// SortedFile reads sorted lines written by SortedWriter, each line is located by offsets file for binary search
+type SortedFile struct {
+	data, offs *os.File
+	Len        int // amount of lines
+}
+
+func OpenSortedFile(path string) (*SortedFile, error) {
+	data, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	offs, err := os.Open(path + ".off")
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	fi, err := offs.Stat()
+	if err != nil {
+		data.Close()
+		offs.Close()
+		return nil, err
+	}
+	if fi.Size() < 8 || fi.Size()%8 != 0 {
+		data.Close()
+		offs.Close()
+		return nil, fmt.Errorf("invalid offsets file of %q", path)
+	}
+	return &SortedFile{data: data, offs: offs, Len: int(fi.Size()/8) - 1}, nil
+}
+
+// Line returns the i-th line without newline
+func (sf *SortedFile) Line(i int) (string, error) {
+	var offs [16]byte
+	if _, err := sf.offs.ReadAt(offs[:], int64(i)*8); err != nil {
+		return "", err
+	}
+	start, end := binary.BigEndian.Uint64(offs[:8]), binary.BigEndian.Uint64(offs[8:])
+	if end <= start {
+		return "", fmt.Errorf("invalid offsets of line %d", i)
+	}
+	buf := make([]byte, end-start-1)
+	if _, err := sf.data.ReadAt(buf, int64(start)); err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// Search returns the index of the first line which is not less than key
+func (sf *SortedFile) Search(key string) (int, error) {
+	var err error
+	i := sort.Search(sf.Len, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var line string
+		line, err = sf.Line(i)
+		return line >= key
+	})
+	return i, err
+}
+
+// Scan calls f with lines starting with prefix in order until f returns false
+func (sf *SortedFile) Scan(prefix string, f func(line string) bool) error {
+	i, err := sf.Search(prefix)
+	if err != nil {
+		return err
+	}
+	for ; i < sf.Len; i++ {
+		line, err := sf.Line(i)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, prefix) || !f(line) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (sf *SortedFile) Close() error {
+	return errors.Join(sf.data.Close(), sf.offs.Close())
+}
@@ -0,0 +1,170 @@
+package file
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestDump(t *testing.T, path string, lines ...string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+}
+
+var testDump = []string{
+	`{"timestamp":"1653619515","name":"www.example.com","type":"a","value":"1.1.1.1"}`,
+	`{"timestamp":"1653619515","name":"API.example.com.","type":"a","value":"1.1.1.2"}`,
+	`{"timestamp":"1653619515","name":"www.example.com","type":"aaaa","value":"2001:db8::1"}`,
+	`{"timestamp":"1653619515","name":"mail.example.com","type":"cname","value":"www.example.com"}`,
+	`{"timestamp":"1653619515","name":"example.com","type":"a","value":"1.1.1.1"}`,
+	`{"timestamp":"1653619515","name":"a.b.example.com","type":"a","value":"1.1.1.1"}`,
+	`{"timestamp":"1653619515","name":"www.example-foo.com","type":"a","value":"1.1.1.1"}`,
+	`{"timestamp":"1653619515","name":"www.other.com","type":"a","value":"1.1.1.3"}`,
+	`not json`,
+	`{"timestamp":"1653619515","name":"bad name.example.com","type":"a","value":"1.1.1.1"}`,
+	`{"timestamp":"1653619515","name":"www.example.com","type":"a","value":"1.1.1.1"}`,
+}
+
+func TestReverseName(t *testing.T) {
+	assert.Equal(t, "com.example.www", ReverseName("www.example.com"))
+	assert.Equal(t, "com", ReverseName("com"))
+	assert.Equal(t, "www.example.com", ReverseName(ReverseName("www.example.com")))
+}
+
+func TestBuildIndex(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "fdns.json.gz")
+	writeTestDump(t, dump, testDump...)
+	// small chunks to merge multiple chunk files
+	require.NoError(t, BuildIndex(dump, filepath.Join(dir, "index"), 2))
+	chunks, err := filepath.Glob(filepath.Join(dir, "index", "*.chunk"))
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	domains, err := OpenSortedFile(filepath.Join(dir, "index", indexDomains))
+	require.NoError(t, err)
+	defer domains.Close()
+	var lines []string
+	for i := 0; i < domains.Len; i++ {
+		line, err := domains.Line(i)
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{
+		"com.example",
+		"com.example-foo.www",
+		"com.example.api",
+		"com.example.b.a",
+		"com.example.mail",
+		"com.example.www",
+		"com.other.www",
+	}, lines)
+	i, err := domains.Search("com.example.")
+	require.NoError(t, err)
+	assert.Equal(t, 2, i)
+	i, err = domains.Search("org")
+	require.NoError(t, err)
+	assert.Equal(t, domains.Len, i)
+
+	ips, err := OpenSortedFile(filepath.Join(dir, "index", indexIPs))
+	require.NoError(t, err)
+	defer ips.Close()
+	var names []string
+	require.NoError(t, ips.Scan("1.1.1.1\t", func(line string) bool {
+		names = append(names, line)
+		return true
+	}))
+	assert.Equal(t, []string{
+		"1.1.1.1\ta.b.example.com", "1.1.1.1\texample.com", "1.1.1.1\twww.example-foo.com", "1.1.1.1\twww.example.com",
+	}, names)
+	// stop scanning once f returns false
+	names = nil
+	require.NoError(t, ips.Scan("1.1.1.1\t", func(line string) bool {
+		names = append(names, line)
+		return false
+	}))
+	assert.Len(t, names, 1)
+}
+
+func TestBuildIndexMergePasses(t *testing.T) {
+	defer func(n int) { maxMergeFiles = n }(maxMergeFiles)
+	maxMergeFiles = 2
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "fdns.json.gz")
+	writeTestDump(t, dump, testDump...)
+	// one line in each chunk, which are merged in passes of 2 chunks
+	require.NoError(t, BuildIndex(dump, filepath.Join(dir, "index"), 1))
+	chunks, err := filepath.Glob(filepath.Join(dir, "index", "*.chunk"))
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	domains, err := OpenSortedFile(filepath.Join(dir, "index", indexDomains))
+	require.NoError(t, err)
+	defer domains.Close()
+	var lines []string
+	require.NoError(t, domains.Scan("", func(line string) bool {
+		lines = append(lines, line)
+		return true
+	}))
+	assert.Equal(t, []string{
+		"com.example",
+		"com.example-foo.www",
+		"com.example.api",
+		"com.example.b.a",
+		"com.example.mail",
+		"com.example.www",
+		"com.other.www",
+	}, lines)
+	ips, err := OpenSortedFile(filepath.Join(dir, "index", indexIPs))
+	require.NoError(t, err)
+	defer ips.Close()
+	assert.Equal(t, 7, ips.Len)
+}
+
+func TestOpenIndex(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "fdns.json.gz")
+	indexDir := filepath.Join(dir, "index")
+	writeTestDump(t, dump, testDump...)
+	idx, err := OpenIndex(dump, indexDir, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 7, idx.Domains.Len)
+	// shared by the same index dir
+	idx2, err := OpenIndex(dump, indexDir, 100)
+	require.NoError(t, err)
+	assert.Same(t, idx, idx2)
+	require.NoError(t, idx2.Close())
+	require.NoError(t, idx.Close())
+
+	// reused if dump does not change
+	fi, err := os.Stat(filepath.Join(indexDir, indexDomains))
+	require.NoError(t, err)
+	idx, err = OpenIndex(dump, indexDir, 100)
+	require.NoError(t, err)
+	fi2, err := os.Stat(filepath.Join(indexDir, indexDomains))
+	require.NoError(t, err)
+	assert.Equal(t, fi.ModTime(), fi2.ModTime())
+	require.NoError(t, idx.Close())
+
+	// rebuilt once dump changes
+	writeTestDump(t, dump, testDump[0])
+	require.NoError(t, os.Chtimes(dump, time.Now(), time.Now().Add(time.Minute)))
+	idx, err = OpenIndex(dump, indexDir, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, idx.Domains.Len)
+	require.NoError(t, idx.Close())
+
+	_, err = OpenIndex(filepath.Join(dir, "notexist.json.gz"), filepath.Join(dir, "index2"), 100)
+	assert.Error(t, err)
+}
@@ -20,11 +20,12 @@ type Querier struct {
 	Out    chan Result
 }
 
-// Query is the message format that sent to Querier.In, Domain OR IP is used for query base on
-// base.SubdomainFinder.RelatedType()
+// Query is the message format that sent to Querier.In, Domain, IP OR CIDR is used for query base on
+// base.SubdomainFinder.ServeType()
 type Query struct {
 	Domain string
 	IP     string
+	CIDR   string
 }
 
 // Result is the API query result for each domain(ip), with result subdomains in list,
@@ -32,10 +33,12 @@ type Query struct {
 type Result struct {
 	Domain         string
 	IP             string
+	CIDR           string
 	Subdomains     []string
 	RelationMethod string // cert/crtsh, api/sublist3r, ...
 	RelationType   string // related-domain, subdomains, ...
 	IType          base.InputType
+	ExInfo         base.ExInfo // extra information of subdomains, only from base.InfoFinder
 	Err            error
 }
 
@@ -87,26 +90,39 @@ func (q Queriers) StartWorkers(ctx context.Context, filter func(item *Querier) b
 		var wg sync.WaitGroup
 		wg.Add(item.Client.Workers())
 		for i := 0; i < item.Client.Workers(); i++ {
-			go func(item *Querier, wg *sync.WaitGroup) {
+			go func(id int, item *Querier, wg *sync.WaitGroup) {
+				wctx := base.WithWorker(ctx, id)
 				rm := item.Client.RelatedMethod() + "/" + string(item.Name)
 				rt := item.Client.RelatedType()
 				for query := range item.In {
+					if ctx.Err() != nil {
+						// drain the remaining queries without querying once canceled
+						continue
+					}
 					result := Result{
 						Domain:         query.Domain,
 						RelationMethod: rm,
 						RelationType:   rt,
 						IType:          base.InputDomain,
 					}
-					if item.Client.ServeType() == base.InputDomain {
-						result.Subdomains, result.Err = item.Client.Get(ctx, query.Domain)
-					} else if item.Client.ServeType() == base.InputIP {
-						result.Subdomains, result.Err = item.Client.Get(ctx, query.IP)
+					input := query.Domain
+					switch item.Client.ServeType() {
+					case base.InputIP:
+						input = query.IP
 						result.IP, result.IType = query.IP, base.InputIP
+					case base.InputCIDR:
+						input = query.CIDR
+						result.CIDR, result.IType = query.CIDR, base.InputCIDR
+					}
+					if infoFinder, ok := item.Client.(base.InfoFinder); ok {
+						result.Subdomains, result.ExInfo, result.Err = infoFinder.GetWithInfo(wctx, input)
+					} else {
+						result.Subdomains, result.Err = item.Client.Get(wctx, input)
 					}
 					item.Out <- result
 				}
 				wg.Done()
-			}(item, &wg)
+			}(i, item, &wg)
 		}
 		go func(item *Querier, wg *sync.WaitGroup) {
 			wg.Wait()
@@ -49,6 +49,51 @@ func (Test3) ServeType() base.InputType {
 	return base.InputIP
 }
 
+// Test4 provides extra information of found domains
+type Test4 struct{ base.SDFinder }
+
+func (t4 *Test4) GetWithInfo(ctx context.Context, domain string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() { t4.RecordStat(subdomains, err) }()
+	info = make(base.ExInfo)
+	info.Set("info.abc.com", "ip", "1.1.1.1")
+	info.Set("info.abc.com", "last_seen", "2022-05-30T11:55:07")
+	return []string{"info.abc.com", "noinfo.abc.com"}, info, nil
+}
+
+func (t4 *Test4) Get(ctx context.Context, domain string) ([]string, error) {
+	subdomains, _, err := t4.GetWithInfo(ctx, domain)
+	return subdomains, err
+}
+
+func (Test4) Name() string { return "test4" }
+
+func (Test4) RelatedMethod() string { return "related4" }
+
+// Test5 sweeps cidr, which returns the host in the cidr with extra information
+type Test5 struct{ base.SDFinder }
+
+func (t5 *Test5) GetWithInfo(ctx context.Context, cidr string) (subdomains []string, info base.ExInfo, err error) {
+	defer func() { t5.RecordStat(subdomains, err) }()
+	info = make(base.ExInfo)
+	info.Set("sibling.xyz.com", "ip", "111.222.111.1")
+	return []string{"sibling.xyz.com"}, info, nil
+}
+
+func (t5 *Test5) Get(ctx context.Context, cidr string) ([]string, error) {
+	subdomains, _, err := t5.GetWithInfo(ctx, cidr)
+	return subdomains, err
+}
+
+func (Test5) Name() string { return "test5" }
+
+func (Test5) RelatedMethod() string { return "related5" }
+
+func (Test5) RelatedType() string { return base.RLPRvsDNS }
+
+func (Test5) ServeType() base.InputType {
+	return base.InputCIDR
+}
+
 func TestQueriers(t *testing.T) {
 	sdFinder := base.NewSDFinder()
 	sdFinder2 := base.NewSDFinder()
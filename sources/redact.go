@@ -0,0 +1,31 @@
+package sources
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+// RedactHook removes secrets such as api keys from message and fields of every log line
+type RedactHook struct{}
+
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = base.Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch val := v.(type) {
+		case string:
+			entry.Data[k] = base.Redact(val)
+		case error:
+			if msg := base.Redact(val.Error()); msg != val.Error() {
+				entry.Data[k] = errors.New(msg)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package sources
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shlin168/sdfinder/sources/base"
+)
+
+func TestRedactHook(t *testing.T) {
+	base.AddSecret("hook-secret")
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.AddHook(RedactHook{})
+	logger.WithField("url", "https://abc.com/?key=hook-secret").
+		WithError(errors.New("get hook-secret failed")).
+		Warnf("query with hook-secret")
+	assert.NotContains(t, buf.String(), "hook-secret")
+	assert.Contains(t, buf.String(), "REDACTED")
+}